@@ -0,0 +1,528 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	追加日志持久化
+Notice:
+	1. 每次修改 key 后，将 key 修改后的完整值追加到 Filename + ".aof"，删除、过期、淘汰记录为删除
+	2. 集合类型每次修改只记录修改的元素，例如 HSet 记录 field 和 value，LPush 记录写入的元素
+	3. 启动时先加载快照，再按顺序重放 ".aof.1" 和 ".aof"
+	   快照和日志使用相同的格式：第一行是记录 Codec 和代数的文件头，之后每行一条记录
+	4. 日志超过 AppendCompactSize 时在后台压缩：锁住所有分片，生成快照并把当前日志移到 ".aof.1"，
+	   快照写入临时文件后重命名，成功后删除 ".aof.1"，任何一步崩溃都可以恢复
+	5. 每次压缩日志的代数加 1，快照记录压缩后新日志的代数，重放时跳过代数更小的日志，
+	   集合类型的记录不能重复重放，快照写入后还没有删除 ".aof.1" 时崩溃也不会重复
+*/
+
+// FsyncPolicy 追加日志同步到磁盘的策略
+type FsyncPolicy int
+
+const (
+	// FsyncEverySec 每秒同步一次，系统崩溃时最多丢失 1 秒的数据
+	FsyncEverySec FsyncPolicy = iota
+	// FsyncAlways 每次写入都同步，最安全但最慢
+	FsyncAlways
+	// FsyncNever 只写入系统缓存，由系统决定何时同步
+	FsyncNever
+)
+
+func (p FsyncPolicy) String() string {
+	switch p {
+	case FsyncAlways:
+		return "always"
+	case FsyncNever:
+		return "never"
+	default:
+		return "everysec"
+	}
+}
+
+// DefaultAppendCompactSize 日志超过 64MB 时压缩
+const DefaultAppendCompactSize = 64 << 20
+
+const (
+	aofHeader = "header"
+	aofSet    = "set"
+	aofDel    = "del"
+	aofFlush  = "flush"
+
+	// 集合类型的修改，Args 为 Codec 编码的参数
+	aofHSet  = "hset"
+	aofHDel  = "hdel"
+	aofLPush = "lpush"
+	aofRPush = "rpush"
+	aofLPop  = "lpop"
+	aofRPop  = "rpop"
+	aofLTrim = "ltrim"
+	aofZAdd  = "zadd"
+	aofZRem  = "zrem"
+)
+
+// fileVersion 快照和日志的格式版本
+// 版本 2 起没有过期时间的 key 的 ExpiredTime 为零值，之前为 100 年后
+const fileVersion = 2
+
+// aofRecord 快照或日志中的一行
+type aofRecord struct {
+	Op  string        `json:"op"`
+	Key string        `json:"key,omitempty"`
+	Val *encodedValue `json:"val,omitempty"`
+	// 集合类型修改的参数
+	Args []byte `json:"args,omitempty"`
+	// 文件头
+	Version int    `json:"version,omitempty"`
+	Codec   string `json:"codec,omitempty"`
+	// Gen 日志的代数，快照中为之后日志的代数
+	Gen int64 `json:"gen,omitempty"`
+}
+
+// headerLine 文件头，之后的记录使用 codec 编码
+func headerLine(codec Codec, gen int64) []byte {
+	byt, _ := json.Marshal(aofRecord{Op: aofHeader, Version: fileVersion, Codec: codec.Name(), Gen: gen})
+	return append(byt, '\n')
+}
+
+// isSnapshotHeader 第一行是否是文件头，否则是旧版本的 JSON 快照
+func isSnapshotHeader(byt []byte) bool {
+	if i := bytes.IndexByte(byt, '\n'); i >= 0 {
+		byt = byt[:i]
+	}
+	var rec aofRecord
+	return json.Unmarshal(byt, &rec) == nil && rec.Op == aofHeader
+}
+
+type appendLog struct {
+	mutex       sync.Mutex
+	filename    string
+	codec       Codec
+	gen         int64
+	file        *os.File
+	fsync       FsyncPolicy
+	size        int64
+	compactSize int64
+	// 有写入但还没有同步到磁盘
+	dirty  bool
+	closed bool
+	// 是否正在后台压缩
+	compacting int32
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// openAppendLog 文件为空或者 Codec 与之前不同时写入文件头
+func openAppendLog(filename string, codec Codec, gen int64, writeHeader bool, fsync FsyncPolicy, compactSize int64) (*appendLog, error) {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, os.FileMode(0666))
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	size := info.Size()
+	if size == 0 || writeHeader {
+		n, err := file.Write(headerLine(codec, gen))
+		size += int64(n)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if compactSize <= 0 {
+		compactSize = DefaultAppendCompactSize
+	}
+	l := &appendLog{
+		filename:    filename,
+		codec:       codec,
+		gen:         gen,
+		file:        file,
+		fsync:       fsync,
+		size:        size,
+		compactSize: compactSize,
+		stop:        make(chan struct{}),
+	}
+	if fsync == FsyncEverySec {
+		l.wg.Add(1)
+		go l.syncEverySec()
+	}
+	return l, nil
+}
+
+// append 写入一行，需要压缩时返回 true，调用方负责压缩并在完成后调用 compactDone
+func (l *appendLog) append(line []byte) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return false
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		log.Printf("WARNING: write append log error %s \n", err.Error())
+		return false
+	}
+	if l.fsync == FsyncAlways {
+		if err := l.file.Sync(); err != nil {
+			log.Printf("WARNING: sync append log error %s \n", err.Error())
+		}
+	} else {
+		l.dirty = true
+	}
+	if l.size < l.compactSize || !atomic.CompareAndSwapInt32(&l.compacting, 0, 1) {
+		return false
+	}
+	l.wg.Add(1)
+	return true
+}
+
+func (l *appendLog) compactDone() {
+	atomic.StoreInt32(&l.compacting, 0)
+	l.wg.Done()
+}
+
+func (l *appendLog) syncEverySec() {
+	defer l.wg.Done()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.mutex.Lock()
+			if l.dirty {
+				if err := l.file.Sync(); err != nil {
+					log.Printf("WARNING: sync append log error %s \n", err.Error())
+				}
+				l.dirty = false
+			}
+			l.mutex.Unlock()
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// rotate 将当前日志移到 filename.1，之后写入代数加 1 的新日志，调用方持有所有分片的锁和 saveMutex
+// 上一次压缩没有完成时 filename.1 仍然存在，将当前日志追加到它后面
+func (l *appendLog) rotate() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	rotated := l.filename + ".1"
+	var err error
+	if FilenameExists(rotated) {
+		err = appendFileTo(rotated, l.filename)
+	} else {
+		// 打开的文件重命名后仍然可以写入
+		err = os.Rename(l.filename, rotated)
+	}
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, os.FileMode(0666))
+	if err != nil {
+		return err
+	}
+	l.file.Close()
+	l.file = file
+	l.dirty = false
+	l.gen++
+	n, err := file.Write(headerLine(l.codec, l.gen))
+	l.size = int64(n)
+	return err
+}
+
+// appendFileTo 将 src 的内容追加到 dst
+func appendFileTo(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, os.FileMode(0666))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// close 等待后台压缩完成后同步并关闭文件，之后的写入会被忽略
+func (l *appendLog) close() error {
+	l.mutex.Lock()
+	if l.closed {
+		l.mutex.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mutex.Unlock()
+	close(l.stop)
+	l.wg.Wait()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if err := l.file.Sync(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// openAppendLog 重放日志后开始追加写入，gen 为快照中记录的代数
+func (mem *MemCache) openAppendLog(fsync FsyncPolicy, compactSize int64, gen int64) error {
+	filename := mem.filename + ".aof"
+	_, rotated, err := mem.replayFile(filename+".1", gen)
+	if err != nil {
+		return err
+	}
+	offset, header, err := mem.replayFile(filename, gen)
+	if err != nil {
+		return err
+	}
+	// 去掉末尾不完整的记录，否则之后写入的记录会接在它后面
+	if info, err := os.Stat(filename); err == nil && info.Size() > offset {
+		if err := os.Truncate(filename, offset); err != nil {
+			return err
+		}
+	}
+	// 继续使用已有日志的代数，不能小于快照和 ".aof.1" 的代数
+	logGen := header.Gen
+	for _, g := range []int64{gen, rotated.Gen} {
+		if g > logGen {
+			logGen = g
+		}
+	}
+	// Codec 或版本不同时写入新的文件头，之后的记录按新的文件头读取
+	writeHeader := header.Codec != mem.codec.Name() || header.Version != fileVersion
+	aof, err := openAppendLog(filename, mem.codec, logGen, writeHeader, fsync, compactSize)
+	if err != nil {
+		return err
+	}
+	mem.aof = aof
+	return nil
+}
+
+func (mem *MemCache) replayFile(filename string, minGen int64) (int64, aofRecord, error) {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return 0, defaultHeader(), nil
+	}
+	if err != nil {
+		return 0, defaultHeader(), err
+	}
+	defer file.Close()
+	return mem.replay(file, filename, minGen)
+}
+
+// defaultHeader 没有文件头时按当前版本的 JSON 读取
+func defaultHeader() aofRecord {
+	return aofRecord{Op: aofHeader, Version: fileVersion, Codec: JSONCodec.Name()}
+}
+
+// replay 返回有效记录的长度和最后的文件头，遇到不完整或损坏的记录时停止
+// 代数小于 minGen 的记录已经包含在快照中，跳过。重放时还没有开启日志，不会再次写入
+func (mem *MemCache) replay(r io.Reader, source string, minGen int64) (int64, aofRecord, error) {
+	reader := bufio.NewReader(r)
+	header := defaultHeader()
+	codec := JSONCodec
+	offset := int64(0)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				log.Printf("WARNING: %s incomplete at offset %d \n", source, offset)
+			}
+			return offset, header, nil
+		}
+		if err != nil {
+			return offset, header, err
+		}
+		var rec aofRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			log.Printf("WARNING: %s corrupted at offset %d \n", source, offset)
+			return offset, header, nil
+		}
+		offset += int64(len(line))
+		if rec.Op == aofHeader {
+			if codec, err = codecByName(rec.Codec); err != nil {
+				return offset, header, err
+			}
+			header = rec
+			continue
+		}
+		if header.Gen < minGen {
+			continue
+		}
+		if err := mem.replayRecord(codec, header.Version, rec); err != nil {
+			log.Printf("WARNING: %s skip key %s: %s \n", source, rec.Key, err.Error())
+		}
+	}
+}
+
+func (mem *MemCache) replayRecord(codec Codec, version int, rec aofRecord) error {
+	switch rec.Op {
+	case aofSet:
+		if rec.Val == nil {
+			return nil
+		}
+		val, err := decodeValue(codec, rec.Val)
+		if err != nil {
+			return err
+		}
+		if version < 2 {
+			restoreLegacyExpire(&val)
+		}
+		if val.Expired() {
+			mem.removeSilent(rec.Key)
+			return nil
+		}
+		return mem.set(rec.Key, val)
+	case aofDel:
+		mem.removeSilent(rec.Key)
+	case aofFlush:
+		mem.lockAll()
+		mem.flushLocked(removeSilent)
+		mem.unlockAll()
+	case aofHSet:
+		var fields map[string]interface{}
+		if err := codec.Unmarshal(rec.Args, &fields); err != nil {
+			return err
+		}
+		for field, value := range fields {
+			if err := mem.HSet(rec.Key, field, value).Error(); err != nil {
+				return err
+			}
+		}
+	case aofLPush, aofRPush:
+		var values []interface{}
+		if err := codec.Unmarshal(rec.Args, &values); err != nil {
+			return err
+		}
+		return mem.push(rec.Key, rec.Op == aofLPush, values).Error()
+	case aofLPop, aofRPop:
+		return mem.pop(rec.Key, rec.Op == aofLPop).Error()
+	case aofLTrim:
+		var r []int64
+		if err := codec.Unmarshal(rec.Args, &r); err != nil {
+			return err
+		}
+		if len(r) != 2 {
+			return fmt.Errorf("cache: invalid ltrim args %v", r)
+		}
+		return mem.LTrim(rec.Key, r[0], r[1]).Error()
+	case aofZAdd:
+		var members []Z
+		if err := codec.Unmarshal(rec.Args, &members); err != nil {
+			return err
+		}
+		return mem.ZAdd(rec.Key, members...).Error()
+	case aofHDel, aofZRem:
+		var names []string
+		if err := codec.Unmarshal(rec.Args, &names); err != nil {
+			return err
+		}
+		if rec.Op == aofHDel {
+			return mem.HDel(rec.Key, names...).Error()
+		}
+		return mem.ZRem(rec.Key, names...).Error()
+	}
+	return nil
+}
+
+func (mem *MemCache) removeSilent(key string) {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	if val, ok := sh.store[key]; ok {
+		mem.removeLocked(sh, key, val, removeSilent)
+	}
+	mem.unlock(sh)
+}
+
+// logSetLocked 记录 key 修改后的值并计入修改次数，调用方持有分片写锁
+func (mem *MemCache) logSetLocked(key string, val WrapValue) {
+	atomic.AddInt64(&mem.changes, 1)
+	if mem.aof == nil {
+		return
+	}
+	ev, err := encodeValue(mem.codec, val)
+	if err != nil {
+		log.Printf("WARNING: encode append log error %s \n", err.Error())
+		return
+	}
+	mem.appendRecord(aofRecord{Op: aofSet, Key: key, Val: ev})
+}
+
+// logCollectionLocked 记录集合类型修改的元素并计入修改次数，调用方持有分片写锁
+func (mem *MemCache) logCollectionLocked(key, op string, args interface{}) {
+	atomic.AddInt64(&mem.changes, 1)
+	if mem.aof == nil {
+		return
+	}
+	rec := aofRecord{Op: op, Key: key}
+	if args != nil {
+		byt, err := mem.codec.Marshal(args)
+		if err != nil {
+			log.Printf("WARNING: encode append log error %s \n", err.Error())
+			return
+		}
+		rec.Args = byt
+	}
+	mem.appendRecord(rec)
+}
+
+func (mem *MemCache) logDelLocked(key string) {
+	atomic.AddInt64(&mem.changes, 1)
+	if mem.aof == nil {
+		return
+	}
+	mem.appendRecord(aofRecord{Op: aofDel, Key: key})
+}
+
+func (mem *MemCache) logFlushLocked() {
+	atomic.AddInt64(&mem.changes, 1)
+	if mem.aof == nil {
+		return
+	}
+	mem.appendRecord(aofRecord{Op: aofFlush})
+}
+
+func (mem *MemCache) appendRecord(rec aofRecord) {
+	byt, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("WARNING: encode append log error %s \n", err.Error())
+		return
+	}
+	if mem.aof.append(append(byt, '\n')) {
+		go mem.compactAppendLog()
+	}
+}
+
+// compactAppendLog 后台压缩，即生成一次快照
+func (mem *MemCache) compactAppendLog() {
+	defer mem.aof.compactDone()
+	if saveCmd := mem.Save(); saveCmd.Error() != nil {
+		log.Printf("WARNING: compact append log error %s \n", saveCmd.Error().Error())
+	}
+}
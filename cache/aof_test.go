@@ -0,0 +1,329 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func newAOFOptions(filename string) Options {
+	opt := NewDefaultOptions()
+	opt.Filename = filename
+	opt.AppendOnly = true
+	opt.AppendFsync = FsyncAlways
+	return opt
+}
+
+func removeAOFFiles(filename string) {
+	for _, name := range []string{filename, filename + ".tmp", filename + ".aof", filename + ".aof.1"} {
+		os.Remove(name)
+	}
+}
+
+// crash 不保存快照，直接关闭日志
+func crash(t *testing.T, memCache *MemCache) {
+	if err := memCache.aof.close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemCache_AppendOnly(t *testing.T) {
+	opt := newAOFOptions("./cache_aof.bak")
+	defer removeAOFFiles(opt.Filename)
+	memCache := NewMemCache(opt)
+
+	memCache.Set("flushed", 1, -1)
+	memCache.FlushAll()
+	memCache.Set("k1", "v1", -1)
+	memCache.Set("k2", "v2", -1)
+	memCache.Delete("k2")
+	memCache.Set("expired", 1, time.Millisecond)
+	memCache.IncrBy("n", 2, 0)
+	memCache.HSet("h", "f", "v")
+	memCache.RPush("l", "a", "b")
+	memCache.LPop("l")
+	memCache.ZAdd("z", Z{Score: 1, Member: "m"})
+	memCache.SetWithTags("tagged", 1, -1, "t")
+	crash(t, memCache)
+	time.Sleep(5 * time.Millisecond)
+
+	memCache = NewMemCache(opt)
+	if keys := memCache.Keys("").Val(); len(keys) != 6 {
+		t.Fatal(keys)
+	}
+	if getCmd := memCache.Get("k1"); getCmd.ValString() != "v1" {
+		t.Fatal(getCmd.ValString())
+	}
+	if n, _ := memCache.Get("n").Int64(); n != 2 {
+		t.Fatal(n)
+	}
+	if getCmd := memCache.HGet("h", "f"); getCmd.ValString() != "v" {
+		t.Fatal(getCmd.ValString())
+	}
+	if rangeCmd := memCache.LRange("l", 0, -1); len(rangeCmd.Val()) != 1 || rangeCmd.Val()[0] != "b" {
+		t.Fatal(rangeCmd.Val())
+	}
+	if scoreCmd := memCache.ZScore("z", "m"); scoreCmd.Val() != 1 {
+		t.Fatal(scoreCmd.Val())
+	}
+	if cmd := memCache.InvalidateTag("t"); cmd.Val() != 1 {
+		t.Fatal(cmd.Val())
+	}
+
+	// Close 生成快照并清空日志
+	if err := memCache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 压缩一次后代数为 1
+	if info, err := os.Stat(opt.Filename + ".aof"); err != nil || info.Size() != int64(len(headerLine(JSONCodec, 1))) {
+		t.Fatal("append log should only have header", err)
+	}
+	if FilenameExists(opt.Filename + ".aof.1") {
+		t.Fatal("rotated log should be removed")
+	}
+	memCache = NewMemCache(opt)
+	defer memCache.Close()
+	if keys := memCache.Keys("").Val(); len(keys) != 5 {
+		t.Fatal(keys)
+	}
+}
+
+func TestMemCache_AppendOnlyTruncated(t *testing.T) {
+	opt := newAOFOptions("./cache_aof_truncated.bak")
+	defer removeAOFFiles(opt.Filename)
+	memCache := NewMemCache(opt)
+	memCache.Set("k1", "v1", -1)
+	crash(t, memCache)
+
+	// 写入过程中崩溃，最后一行不完整
+	f, err := os.OpenFile(opt.Filename+".aof", os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"set","key":"k2","val":{"v":`)
+	f.Close()
+
+	memCache = NewMemCache(opt)
+	if !memCache.Get("k1").Exists() || memCache.Get("k2").Exists() {
+		t.Fatal("should only load complete records")
+	}
+	memCache.Set("k3", "v3", -1)
+	crash(t, memCache)
+
+	memCache = NewMemCache(opt)
+	defer memCache.Close()
+	if !memCache.Get("k1").Exists() || !memCache.Get("k3").Exists() {
+		t.Fatal("records after truncated one should be loaded")
+	}
+}
+
+func TestMemCache_AppendOnlyCompact(t *testing.T) {
+	opt := newAOFOptions("./cache_aof_compact.bak")
+	opt.AppendFsync = FsyncEverySec
+	opt.AppendCompactSize = 1024
+	defer removeAOFFiles(opt.Filename)
+	memCache := NewMemCache(opt)
+	for i := 0; i < 100; i++ {
+		memCache.Set(fmt.Sprintf("k%d", i%20), i, -1)
+	}
+
+	// 等待后台压缩
+	deadline := time.Now().Add(2 * time.Second)
+	for !FilenameExists(opt.Filename) || FilenameExists(opt.Filename+".aof.1") {
+		if time.Now().After(deadline) {
+			t.Fatal("append log should be compacted")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// 压缩期间的写入仍在新的日志中，日志大小不一定小于 AppendCompactSize
+	memCache.Set("k0", "last", -1)
+	crash(t, memCache)
+
+	memCache = NewMemCache(opt)
+	defer memCache.Close()
+	if keys := memCache.Keys("").Val(); len(keys) != 20 {
+		t.Fatal(keys)
+	}
+	if getCmd := memCache.Get("k0"); getCmd.ValString() != "last" {
+		t.Fatal(getCmd.ValString())
+	}
+	if n, _ := memCache.Get("k19").Int64(); n != 99 {
+		t.Fatal(n)
+	}
+}
+
+func TestMemCache_AppendOnlyInterruptedCompact(t *testing.T) {
+	opt := newAOFOptions("./cache_aof_interrupted.bak")
+	defer removeAOFFiles(opt.Filename)
+
+	setLine := func(key string, value interface{}) string {
+		val := WrapValue{Value: value}
+		val.SetExpiredTime(-1)
+		ev, err := encodeValue(JSONCodec, val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byt, _ := json.Marshal(aofRecord{Op: aofSet, Key: key, Val: ev})
+		return string(byt) + "\n"
+	}
+
+	// 压缩时日志已经移到 .aof.1，但快照还没有写入，快照是旧版本的格式
+	ioutil.WriteFile(opt.Filename, []byte(`{"old":{"v":"snapshot","e":"2999-01-01T00:00:00Z","s":0}}`), 0666)
+	ioutil.WriteFile(opt.Filename+".aof.1", []byte(
+		string(headerLine(JSONCodec, 0))+setLine("k1", "v1")+`{"op":"del","key":"old"}`+"\n"), 0666)
+	ioutil.WriteFile(opt.Filename+".aof", []byte(string(headerLine(JSONCodec, 0))+setLine("k2", "v2")), 0666)
+
+	memCache := NewMemCache(opt)
+	if memCache.Get("old").Exists() || !memCache.Get("k1").Exists() || !memCache.Get("k2").Exists() {
+		t.Fatal(memCache.Keys("").Val())
+	}
+	// 再次压缩时合并到 .aof.1
+	memCache.Set("k3", "v3", -1)
+	memCache.rLockAll()
+	err := memCache.aof.rotate()
+	memCache.rUnlockAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	crash(t, memCache)
+
+	memCache = NewMemCache(opt)
+	defer memCache.Close()
+	if keys := memCache.Keys("").Val(); len(keys) != 3 {
+		t.Fatal(keys)
+	}
+}
+
+func TestMemCache_AppendOnlyLegacyVersion(t *testing.T) {
+	opt := newAOFOptions("./cache_aof_legacy.bak")
+	defer removeAOFFiles(opt.Filename)
+
+	// 版本 1 的日志中没有过期时间的 key 保存为 100 年后
+	legacy := fmt.Sprintf(`{"op":"header","version":1,"codec":"json"}
+{"op":"set","key":"k1","val":{"v":"InYxIg==","type":"string","e":%q,"s":4}}
+`, time.Now().AddDate(100, 0, 0).Format(time.RFC3339))
+	ioutil.WriteFile(opt.Filename+".aof", []byte(legacy), 0666)
+
+	memCache := NewMemCache(opt)
+	if cmd := memCache.Get("k1"); cmd.ValString() != "v1" || cmd.TTL() != -1 {
+		t.Fatal(cmd.ValString(), cmd.TTL())
+	}
+	// 之后的记录写在新版本的文件头后面，超过 50 年的过期时间保持不变
+	memCache.ExpireAt("k1", time.Now().AddDate(60, 0, 0))
+	crash(t, memCache)
+
+	memCache = NewMemCache(opt)
+	defer memCache.Close()
+	if ttl := memCache.Get("k1").TTL(); ttl < 59*365*24*time.Hour {
+		t.Fatal(ttl)
+	}
+}
+
+// readAOFRecords 读取日志中除文件头以外的记录
+func readAOFRecords(t *testing.T, filename string) []aofRecord {
+	byt, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := make([]aofRecord, 0)
+	for _, line := range bytes.Split(bytes.TrimSpace(byt), []byte("\n")) {
+		var rec aofRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Op != aofHeader {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+func TestMemCache_AppendOnlyCollection(t *testing.T) {
+	opt := newAOFOptions("./cache_aof_collection.bak")
+	defer removeAOFFiles(opt.Filename)
+	memCache := NewMemCache(opt)
+
+	memCache.HSet("h", "f1", "v1")
+	memCache.HSet("h", "f2", "v2")
+	memCache.HIncrBy("h", "n", 3)
+	memCache.HDel("h", "f1", "not_exists")
+	memCache.HDel("h", "not_exists")
+	memCache.RPush("l", "a", "b", "c", "d")
+	memCache.LPush("l", "x", "y")
+	memCache.RPop("l")
+	memCache.LTrim("l", 1, -1)
+	memCache.ZAdd("z", Z{Score: 1, Member: "a"}, Z{Score: 2, Member: "b"})
+	memCache.ZIncrBy("z", 2, "a")
+	memCache.ZRem("z", "b")
+	memCache.HSet("empty", "f", "v")
+	memCache.HDel("empty", "f")
+
+	// 每次修改只记录一条，没有修改时不记录，新建时不记录完整的值
+	ops := make([]string, 0)
+	for _, rec := range readAOFRecords(t, opt.Filename+".aof") {
+		if rec.Val != nil {
+			t.Fatal("collection should not log full value", rec)
+		}
+		ops = append(ops, rec.Op)
+	}
+	expected := []string{aofHSet, aofHSet, aofHSet, aofHDel, aofRPush, aofLPush, aofRPop, aofLTrim, aofZAdd, aofZAdd, aofZRem, aofHSet, aofDel}
+	if fmt.Sprint(ops) != fmt.Sprint(expected) {
+		t.Fatal(ops)
+	}
+	crash(t, memCache)
+
+	memCache = NewMemCache(opt)
+	defer memCache.Close()
+	if fields := memCache.HGetAll("h").Val(); len(fields) != 2 || fields["f2"] != "v2" {
+		t.Fatal(fields)
+	}
+	if n, err := memCache.HGet("h", "n").Int64(); err != nil || n != 3 {
+		t.Fatal(n, err)
+	}
+	if values := memCache.LRange("l", 0, -1).Val(); fmt.Sprint(values) != "[x a b c]" {
+		t.Fatal(values)
+	}
+	if values := memCache.ZRange("z", 0, -1).Val(); len(values) != 1 || values[0].Member != "a" || values[0].Score != 3 {
+		t.Fatal(values)
+	}
+	if memCache.HGetAll("empty").Exists() {
+		t.Fatal("empty hash should be deleted")
+	}
+}
+
+func TestMemCache_AppendOnlyCompactNotReplayTwice(t *testing.T) {
+	opt := newAOFOptions("./cache_aof_twice.bak")
+	defer removeAOFFiles(opt.Filename)
+	memCache := NewMemCache(opt)
+	memCache.RPush("l", "a")
+	rotated, err := ioutil.ReadFile(opt.Filename + ".aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saveCmd := memCache.Save(); saveCmd.Error() != nil {
+		t.Fatal(saveCmd.Error())
+	}
+	// 快照写入后，删除 .aof.1 之前崩溃
+	if err := ioutil.WriteFile(opt.Filename+".aof.1", rotated, 0666); err != nil {
+		t.Fatal(err)
+	}
+	memCache.RPush("l", "b")
+	crash(t, memCache)
+
+	memCache = NewMemCache(opt)
+	if values := memCache.LRange("l", 0, -1).Val(); fmt.Sprint(values) != "[a b]" {
+		t.Fatal(values)
+	}
+	// 新的日志继续使用之前的代数
+	memCache.RPush("l", "c")
+	crash(t, memCache)
+	memCache = NewMemCache(opt)
+	defer memCache.Close()
+	if values := memCache.LRange("l", 0, -1).Val(); fmt.Sprint(values) != "[a b c]" {
+		t.Fatal(values)
+	}
+}
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	后台定期保存快照
+Notice:
+	1. SaveInterval 有修改时每隔一段时间保存一次，SavePoints 类似 redis 的 save 配置，
+	   距离上次保存超过 Interval 且修改次数达到 Changes 时保存
+	2. 手动 Save 和日志压缩同样算作一次保存，之后重新计时
+	3. 保存失败时同样重新计时，避免每次检查都重试
+	4. 写入、删除、过期、淘汰、FlushAll 都计为修改，加载文件不计入
+*/
+
+// SavePoint 距离上次保存超过 Interval 且至少有 Changes 次修改时保存
+type SavePoint struct {
+	Interval time.Duration
+	Changes  int64
+}
+
+// Info 持久化的状态
+type Info struct {
+	// 上次保存之后的修改次数
+	Changes int64
+	// 上次保存成功的时间，没有保存过时为零值
+	LastSaveTime time.Time
+	// 上次保存的耗时和结果，成功时 LastSaveError 为 nil
+	LastSaveDuration time.Duration
+	LastSaveError    error
+	// 保存成功和失败的次数
+	Saves      int64
+	SaveErrors int64
+	// 是否开启追加日志，以及当前日志的大小
+	AppendOnly bool
+	AppendSize int64
+}
+
+type saveInfo struct {
+	mutex sync.Mutex
+	// 上次尝试保存的时间，启动时为创建的时间
+	lastAttempt  time.Time
+	lastSuccess  time.Time
+	lastDuration time.Duration
+	lastErr      error
+	saves        int64
+	errors       int64
+}
+
+// recordSave 保存成功后减去快照包含的修改次数，快照之后的修改仍然保留
+func (mem *MemCache) recordSave(start time.Time, changes int64, err error) {
+	info := &mem.saveInfo
+	info.mutex.Lock()
+	defer info.mutex.Unlock()
+	info.lastAttempt = start
+	info.lastDuration = time.Since(start)
+	info.lastErr = err
+	if err != nil {
+		info.errors++
+		return
+	}
+	info.lastSuccess = start
+	info.saves++
+	atomic.AddInt64(&mem.changes, -changes)
+}
+
+func (mem *MemCache) Info() Info {
+	info := &mem.saveInfo
+	info.mutex.Lock()
+	result := Info{
+		Changes:          atomic.LoadInt64(&mem.changes),
+		LastSaveTime:     info.lastSuccess,
+		LastSaveDuration: info.lastDuration,
+		LastSaveError:    info.lastErr,
+		Saves:            info.saves,
+		SaveErrors:       info.errors,
+	}
+	info.mutex.Unlock()
+	if mem.aof != nil {
+		result.AppendOnly = true
+		mem.aof.mutex.Lock()
+		result.AppendSize = mem.aof.size
+		mem.aof.mutex.Unlock()
+	}
+	return result
+}
+
+// startAutoSave 没有设置 Filename 时不保存
+func (mem *MemCache) startAutoSave(interval time.Duration, points []SavePoint) {
+	mem.saveInfo.mutex.Lock()
+	mem.saveInfo.lastAttempt = time.Now()
+	mem.saveInfo.mutex.Unlock()
+
+	valid := make([]SavePoint, 0, len(points))
+	for _, p := range points {
+		if p.Interval > 0 {
+			valid = append(valid, p)
+		}
+	}
+	if interval <= 0 && len(valid) == 0 {
+		return
+	}
+	if mem.filename == "" {
+		log.Printf("WARNING: auto save need filename \n")
+		return
+	}
+
+	// 最长 1 秒检查一次
+	tick := time.Second
+	if interval > 0 && interval < tick {
+		tick = interval
+	}
+	for _, p := range valid {
+		if p.Interval < tick {
+			tick = p.Interval
+		}
+	}
+	mem.wg.Add(1)
+	go mem.autoSave(tick, interval, valid)
+}
+
+func (mem *MemCache) autoSave(tick, interval time.Duration, points []SavePoint) {
+	defer mem.wg.Done()
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !mem.shouldSave(interval, points) {
+				continue
+			}
+			if saveCmd := mem.Save(); saveCmd.Error() != nil {
+				log.Printf("WARNING: auto save error %s \n", saveCmd.Error().Error())
+			}
+		case <-mem.closing:
+			return
+		}
+	}
+}
+
+func (mem *MemCache) shouldSave(interval time.Duration, points []SavePoint) bool {
+	changes := atomic.LoadInt64(&mem.changes)
+	if changes <= 0 {
+		return false
+	}
+	mem.saveInfo.mutex.Lock()
+	elapsed := time.Since(mem.saveInfo.lastAttempt)
+	mem.saveInfo.mutex.Unlock()
+
+	if interval > 0 && elapsed >= interval {
+		return true
+	}
+	for _, p := range points {
+		if elapsed >= p.Interval && changes >= p.Changes {
+			return true
+		}
+	}
+	return false
+}
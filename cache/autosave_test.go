@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMemCache_SaveInterval(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Filename = "./cache_autosave.bak"
+	opt.SaveInterval = 20 * time.Millisecond
+	defer os.Remove(opt.Filename)
+	memCache := NewMemCache(opt)
+
+	// 没有修改时不保存
+	time.Sleep(50 * time.Millisecond)
+	if info := memCache.Info(); info.Saves != 0 || !info.LastSaveTime.IsZero() {
+		t.Fatal(info)
+	}
+
+	memCache.Set("k1", "v1", -1)
+	memCache.Delete("k1")
+	memCache.Set("k2", "v2", -1)
+	if info := memCache.Info(); info.Changes != 3 {
+		t.Fatal(info)
+	}
+	time.Sleep(100 * time.Millisecond)
+	info := memCache.Info()
+	if info.Saves != 1 || info.Changes != 0 || info.LastSaveTime.IsZero() || info.LastSaveError != nil {
+		t.Fatal(info)
+	}
+
+	// 模拟进程被杀死，不调用 Close
+	loaded := NewMemCache(NewDefaultOptions())
+	loaded.filename = opt.Filename
+	if _, err := loaded.load(); err != nil {
+		t.Fatal(err)
+	}
+	if getCmd := loaded.Get("k2"); getCmd.ValString() != "v2" {
+		t.Fatal(getCmd.ValString())
+	}
+
+	if err := memCache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info := memCache.Info(); info.Saves != 2 {
+		t.Fatal(info)
+	}
+	// Close 之后后台不再保存
+	memCache.Set("k3", "v3", -1)
+	time.Sleep(50 * time.Millisecond)
+	if info := memCache.Info(); info.Saves != 2 || info.Changes != 1 {
+		t.Fatal(info)
+	}
+}
+
+func TestMemCache_SavePoints(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Filename = "./cache_savepoints.bak"
+	opt.SavePoints = []SavePoint{{Interval: 10 * time.Millisecond, Changes: 3}}
+	defer os.Remove(opt.Filename)
+	memCache := NewMemCache(opt)
+	defer memCache.Close()
+
+	memCache.Set("k1", "v1", -1)
+	memCache.Set("k2", "v2", -1)
+	time.Sleep(50 * time.Millisecond)
+	if info := memCache.Info(); info.Saves != 0 || info.Changes != 2 {
+		t.Fatal(info)
+	}
+	memCache.Set("k3", "v3", -1)
+	time.Sleep(50 * time.Millisecond)
+	if info := memCache.Info(); info.Saves != 1 || info.Changes != 0 {
+		t.Fatal(info)
+	}
+}
+
+func TestMemCache_InfoSaveError(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Filename = "./cache_autosave_error.bak"
+	opt.Codec = RawCodec
+	defer os.Remove(opt.Filename)
+	memCache := NewMemCache(opt)
+
+	// RawCodec 不能编码数字，保存失败
+	memCache.Set("n", 1, -1)
+	if saveCmd := memCache.Save(); saveCmd.Error() == nil {
+		t.Fatal("expect error")
+	}
+	info := memCache.Info()
+	if info.SaveErrors != 1 || info.LastSaveError == nil || info.Changes != 1 || !info.LastSaveTime.IsZero() {
+		t.Fatal(info)
+	}
+
+	memCache.Delete("n")
+	if saveCmd := memCache.Save(); saveCmd.Error() != nil {
+		t.Fatal(saveCmd.Error())
+	}
+	info = memCache.Info()
+	if info.Saves != 1 || info.LastSaveError != nil || info.Changes != 0 {
+		t.Fatal(info)
+	}
+	if err := memCache.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
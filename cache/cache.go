@@ -1,19 +1,66 @@
 package cache
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 )
 
+var (
+	// ErrNil key 不存在或者值为 nil 时，类型转换返回该错误
+	ErrNil = errors.New("cache: nil value")
+	// ErrNotInteger 对非整数或者会溢出的值做整数增减
+	ErrNotInteger = errors.New("cache: value is not an integer or out of range")
+	// ErrNotFloat 对非数字的值做浮点增减
+	ErrNotFloat = errors.New("cache: value is not a valid float")
+	// ErrWrongType 对 key 执行了不属于它数据类型的操作
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+)
+
+// Cache 写入值时 ttl <= 0 不过期，与 redis 一致，Expire 除外
 type Cache interface {
 	Get(key string) *Cmd
 	Set(key string, value interface{}, ttl time.Duration) *StatusCmd
 
 	// prefix - 前缀查询，"" 查询所有， 只返回当前有效的key
 	Keys(prefix string) *SliceStringCmd
+	// Scan 从 cursor 开始返回约 count 个匹配 pattern 的 key 和下一次的 cursor，cursor 为 0 时开始和结束
+	// pattern 支持 redis 风格的 *、?、[abc]
+	Scan(cursor uint64, pattern string, count int64) *ScanCmd
 
 	Delete(key string) *StatusCmd
 
+	// 修改过期时间，不修改值，key 不存在时返回 false
+	// Expire ExpireAt 与 redis 一致，ttl <= 0 或 tm 已经过去时删除 key
+	Expire(key string, ttl time.Duration) *BoolCmd
+	ExpireAt(key string, tm time.Time) *BoolCmd
+	// Persist 取消过期时间，key 没有过期时间时返回 false
+	Persist(key string) *BoolCmd
+	// Touch 与 Expire 相同，同时算作一次访问
+	Touch(key string, ttl time.Duration) *BoolCmd
+
+	// 原子增减，key 不存在时从 0 开始并创建，ttl 只在创建时生效，<= 0 不过期
+	IncrBy(key string, value int64, ttl time.Duration) *IntCmd
+	DecrBy(key string, value int64, ttl time.Duration) *IntCmd
+	IncrByFloat(key string, value float64, ttl time.Duration) *FloatCmd
+
+	// 条件写入，写入成功返回 true
+	// SetNX key 不存在时写入，SetXX key 存在时写入
+	SetNX(key string, value interface{}, ttl time.Duration) *BoolCmd
+	SetXX(key string, value interface{}, ttl time.Duration) *BoolCmd
+	// GetSet 写入新值，返回旧值
+	GetSet(key string, value interface{}, ttl time.Duration) *Cmd
+	// CompareAndSwap 当前值等于 old 时写入 new，key 不存在时不写入
+	CompareAndSwap(key string, old, new interface{}, ttl time.Duration) *BoolCmd
+
+	// 批量操作
+	// MGet 按 keys 的顺序返回每个 key 的结果
+	MGet(keys ...string) *MultiCmd
+	MSet(values map[string]interface{}, ttl time.Duration) *StatusCmd
+	// MDelete 返回实际删除的 key 数量
+	MDelete(keys ...string) *IntCmd
+
 	// 删除所有 key
 	FlushAll() *StatusCmd
 
@@ -22,6 +69,66 @@ type Cache interface {
 	Close() error
 }
 
+// HashCache 哈希类型，语义与 redis hash 一致
+// field 全部删除后 key 也会被删除，过期时间使用 key 的过期时间
+type HashCache interface {
+	// HSet 返回 field 是否是新增的
+	HSet(key, field string, value interface{}) *BoolCmd
+	HGet(key, field string) *Cmd
+	HGetAll(key string) *MapCmd
+	// HDel 返回删除的 field 数量
+	HDel(key string, fields ...string) *IntCmd
+	HIncrBy(key, field string, incr int64) *IntCmd
+}
+
+// ListCache 列表类型，语义与 redis list 一致，元素全部取出后 key 也会被删除
+type ListCache interface {
+	// LPush RPush 返回写入后的长度
+	LPush(key string, values ...interface{}) *IntCmd
+	RPush(key string, values ...interface{}) *IntCmd
+	LPop(key string) *Cmd
+	RPop(key string) *Cmd
+	// BLPop 按顺序从第一个非空的 list 头部取出元素，都为空时阻塞等待
+	// timeout 为 0 时一直等待，直到 ctx 结束，超时返回不存在
+	BLPop(ctx context.Context, timeout time.Duration, keys ...string) *KeyValueCmd
+	// LRange 返回 [start, stop] 范围内的元素，负数表示从尾部开始计算
+	LRange(key string, start, stop int64) *SliceCmd
+	// LTrim 只保留 [start, stop] 范围内的元素
+	LTrim(key string, start, stop int64) *StatusCmd
+	LLen(key string) *IntCmd
+}
+
+// SortedSetCache 有序集合，语义与 redis zset 一致，按 score 从小到大排序，score 相同时按 member 排序
+type SortedSetCache interface {
+	// ZAdd 返回新增的 member 数量，已存在的 member 更新 score
+	ZAdd(key string, members ...Z) *IntCmd
+	// ZIncrBy member 不存在时从 0 开始
+	ZIncrBy(key string, increment float64, member string) *FloatCmd
+	// ZRem 返回删除的 member 数量
+	ZRem(key string, members ...string) *IntCmd
+	// ZRange 按排名 [start, stop] 返回，从 0 开始，负数表示从尾部开始计算
+	ZRange(key string, start, stop int64) *ZSliceCmd
+	// ZRangeByScore 返回 score 在 [min, max] 内的成员，可以使用 math.Inf 表示无穷
+	ZRangeByScore(key string, min, max float64) *ZSliceCmd
+	// ZRank 从 0 开始的排名，member 不存在时 Exists 为 false
+	ZRank(key, member string) *IntCmd
+	ZScore(key, member string) *FloatCmd
+	ZCard(key string) *IntCmd
+}
+
+// TagCache 按标签批量删除，例如删除某个用户相关的所有缓存
+type TagCache interface {
+	// SetWithTags 写入并给 key 打上标签
+	SetWithTags(key string, value interface{}, ttl time.Duration, tags ...string) *StatusCmd
+	// InvalidateTag 原子删除带有 tag 的所有 key，返回删除的 key 数量
+	InvalidateTag(tag string) *IntCmd
+}
+
+// StatsCache 返回缓存的统计信息
+type StatsCache interface {
+	Stats() *StatsCmd
+}
+
 type baseCmd struct {
 	exists bool
 	ttl    time.Duration
@@ -32,6 +139,7 @@ func (cmd *baseCmd) Exists() bool {
 	return cmd.exists
 }
 
+// TTL 剩余的过期时间，key 没有过期时间时为 -1
 func (cmd *baseCmd) TTL() time.Duration {
 	return cmd.ttl
 }
@@ -58,6 +166,102 @@ func (cmd *Cmd) Val() interface{} {
 	return cmd.value
 }
 
+func (cmd *Cmd) Int64() (int64, error) {
+	if cmd.err != nil {
+		return 0, cmd.err
+	}
+	return toInt64(cmd.value)
+}
+
+func (cmd *Cmd) Uint64() (uint64, error) {
+	if cmd.err != nil {
+		return 0, cmd.err
+	}
+	return toUint64(cmd.value)
+}
+
+func (cmd *Cmd) Float64() (float64, error) {
+	if cmd.err != nil {
+		return 0, cmd.err
+	}
+	return toFloat64(cmd.value)
+}
+
+func (cmd *Cmd) Bool() (bool, error) {
+	if cmd.err != nil {
+		return false, cmd.err
+	}
+	return toBool(cmd.value)
+}
+
+func (cmd *Cmd) Time() (time.Time, error) {
+	if cmd.err != nil {
+		return time.Time{}, cmd.err
+	}
+	return toTime(cmd.value)
+}
+
+func (cmd *Cmd) Bytes() ([]byte, error) {
+	if cmd.err != nil {
+		return nil, cmd.err
+	}
+	return toBytes(cmd.value)
+}
+
+// Scan 将值写入 dest，dest 必须是指针。结构体等复杂类型通过 JSON 转换
+func (cmd *Cmd) Scan(dest interface{}) error {
+	if cmd.err != nil {
+		return cmd.err
+	}
+	return scanValue(cmd.value, dest)
+}
+
+// KeyValueCmd 从多个 key 中取值时，同时返回值所在的 key
+type KeyValueCmd struct {
+	Cmd
+	key string
+}
+
+func (cmd *KeyValueCmd) Key() string {
+	return cmd.key
+}
+
+type SliceCmd struct {
+	baseCmd
+	value []interface{}
+}
+
+func (cmd *SliceCmd) Val() []interface{} {
+	return cmd.value
+}
+
+type ZSliceCmd struct {
+	baseCmd
+	value []Z
+}
+
+func (cmd *ZSliceCmd) Val() []Z {
+	return cmd.value
+}
+
+type MultiCmd struct {
+	baseCmd
+	value []*Cmd
+}
+
+func (cmd *MultiCmd) Val() []*Cmd {
+	return cmd.value
+}
+
+type MapCmd struct {
+	baseCmd
+	value map[string]interface{}
+}
+
+func (cmd *MapCmd) Val() map[string]interface{} {
+	return cmd.value
+}
+
 type SliceStringCmd struct {
 	baseCmd
 	value []string
@@ -67,6 +271,39 @@ func (cmd *SliceStringCmd) Val() []string {
 	return cmd.value
 }
 
+type ScanCmd struct {
+	baseCmd
+	value  []string
+	cursor uint64
+}
+
+func (cmd *ScanCmd) Val() []string {
+	return cmd.value
+}
+
+// Cursor 下一次 Scan 的 cursor，为 0 时遍历结束
+func (cmd *ScanCmd) Cursor() uint64 {
+	return cmd.cursor
+}
+
+type IntCmd struct {
+	baseCmd
+	value int64
+}
+
+func (cmd *IntCmd) Val() int64 {
+	return cmd.value
+}
+
+type FloatCmd struct {
+	baseCmd
+	value float64
+}
+
+func (cmd *FloatCmd) Val() float64 {
+	return cmd.value
+}
+
 const (
 	StatusOK = "OK"
 )
@@ -76,6 +313,15 @@ type StatusCmd struct {
 	value string
 }
 
+type StatsCmd struct {
+	baseCmd
+	value Stats
+}
+
+func (cmd *StatsCmd) Val() Stats {
+	return cmd.value
+}
+
 type BoolCmd struct {
 	baseCmd
 	value bool
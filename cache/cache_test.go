@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestCmd_TypedValue(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 123, time.UTC)
+
+	opt := NewDefaultOptions()
+	opt.Filename = "./cache_typed.bak"
+	defer os.Remove(opt.Filename)
+	memCache := NewMemCache(opt)
+	rc, _ := newTestRedisCache(t)
+
+	for _, c := range []Cache{memCache, rc} {
+		c.Set("int", 42, -1)
+		c.Set("uint", uint64(1)<<63, -1)
+		c.Set("float", 1.5, -1)
+		c.Set("bool", true, -1)
+		c.Set("time", now, -1)
+		c.Set("bytes", []byte("hello"), -1)
+		c.Set("user", testUser{Name: "tom", Age: 18}, -1)
+	}
+	if err := memCache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	caches := map[string]Cache{
+		"mem":    memCache,
+		"reload": NewMemCache(opt),
+		"redis":  rc,
+	}
+	for name, c := range caches {
+		if i, err := c.Get("int").Int64(); err != nil || i != 42 {
+			t.Fatal(name, i, err)
+		}
+		if u, err := c.Get("uint").Uint64(); err != nil || u != uint64(1)<<63 {
+			t.Fatal(name, u, err)
+		}
+		if f, err := c.Get("float").Float64(); err != nil || f != 1.5 {
+			t.Fatal(name, f, err)
+		}
+		if _, err := c.Get("float").Int64(); err == nil {
+			t.Fatal(name, "1.5 should not convert to int64")
+		}
+		if b, err := c.Get("bool").Bool(); err != nil || !b {
+			t.Fatal(name, b, err)
+		}
+		if tm, err := c.Get("time").Time(); err != nil || !tm.Equal(now) {
+			t.Fatal(name, tm, err)
+		}
+		if byt, err := c.Get("bytes").Bytes(); err != nil || string(byt) != "hello" {
+			t.Fatal(name, string(byt), err)
+		}
+
+		var user testUser
+		if err := c.Get("user").Scan(&user); err != nil || user.Name != "tom" || user.Age != 18 {
+			t.Fatal(name, user, err)
+		}
+		var age int32
+		if err := c.Get("int").Scan(&age); err != nil || age != 42 {
+			t.Fatal(name, age, err)
+		}
+
+		if _, err := c.Get("not_exists").Int64(); err != ErrNil {
+			t.Fatal(name, err)
+		}
+	}
+}
+
+func TestCache_TTLZero(t *testing.T) {
+	rc, _ := newTestRedisCache(t)
+	caches := map[string]Cache{
+		"mem":   NewMemCache(NewDefaultOptions()),
+		"redis": rc,
+	}
+	for name, c := range caches {
+		// ttl <= 0 不过期
+		c.Set("set", 1, 0)
+		if !c.SetNX("nx", 1, 0).Val() || c.SetNX("nx", 2, 0).Val() {
+			t.Fatal(name, "SetNX with ttl 0 should write once")
+		}
+		if !c.SetXX("set", 2, 0).Val() {
+			t.Fatal(name, "SetXX should write")
+		}
+		c.GetSet("getset", 1, -1)
+		c.CompareAndSwap("nx", 1, 3, 0)
+		c.MSet(map[string]interface{}{"m1": 1, "m2": 2}, 0)
+		c.IncrBy("incr", 1, 0)
+		for _, key := range []string{"set", "nx", "getset", "m1", "m2", "incr"} {
+			if !c.Get(key).Exists() {
+				t.Fatal(name, key, "should not expire")
+			}
+		}
+		if n := c.Get("nx").ValString(); n != "3" {
+			t.Fatal(name, n)
+		}
+
+		loads := 0
+		loader := NewLoader(c, LoaderOptions{})
+		for i := 0; i < 3; i++ {
+			loader.GetOrLoad("loaded", 0, func(string) (interface{}, error) {
+				loads++
+				return "v", nil
+			})
+		}
+		if loads != 1 {
+			t.Fatal(name, loads)
+		}
+	}
+}
+
+func TestCache_PersistentTTL(t *testing.T) {
+	rc, _ := newTestRedisCache(t)
+	caches := map[string]Cache{
+		"mem":   NewMemCache(NewDefaultOptions()),
+		"redis": rc,
+	}
+	for name, c := range caches {
+		// 没有过期时间时 TTL 为 -1
+		if cmd := c.Set("k", "v", 0); cmd.TTL() != -1 {
+			t.Fatal(name, cmd.TTL())
+		}
+		if cmd := c.Get("k"); cmd.TTL() != -1 {
+			t.Fatal(name, cmd.TTL())
+		}
+		if cmd := c.IncrBy("n", 1, -1); cmd.TTL() != -1 {
+			t.Fatal(name, cmd.TTL())
+		}
+		if cmd := c.SetNX("nx", 1, 0); cmd.TTL() != -1 {
+			t.Fatal(name, cmd.TTL())
+		}
+		if cmds := c.MGet("k", "n").Val(); cmds[0].TTL() != -1 || cmds[1].TTL() != -1 {
+			t.Fatal(name, cmds[0].TTL(), cmds[1].TTL())
+		}
+		c.Expire("k", time.Minute)
+		if cmd := c.Get("k"); cmd.TTL() <= 0 || cmd.TTL() > time.Minute {
+			t.Fatal(name, cmd.TTL())
+		}
+		if cmd := c.Persist("k"); !cmd.Val() || cmd.TTL() != -1 || c.Get("k").TTL() != -1 {
+			t.Fatal(name, cmd.TTL())
+		}
+
+		loader := NewLoader(c, LoaderOptions{})
+		for i := 0; i < 2; i++ {
+			cmd := loader.GetOrLoad("loaded", 0, func(string) (interface{}, error) {
+				return "v", nil
+			})
+			if cmd.TTL() != -1 {
+				t.Fatal(name, i, cmd.TTL())
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package cache
+
+/*
+	key 被删除时的回调
+Notice:
+	1. 删除时只记录事件，释放分片锁之后再执行回调，回调中可以继续读写 cache
+	2. 已过期的 key 无论因为什么被删除，reason 都是 RemoveExpired
+	3. 覆盖写入、集合类型元素全部删除后移除 key，不会触发回调
+*/
+
+// RemoveReason key 被删除的原因
+type RemoveReason int
+
+const (
+	// removeSilent 不触发回调
+	removeSilent RemoveReason = iota
+	// RemoveExpired 过期
+	RemoveExpired
+	// RemoveEvicted 容量不足被淘汰
+	RemoveEvicted
+	// RemoveDeleted 主动删除，包括 Delete、MDelete、InvalidateTag
+	RemoveDeleted
+	// RemoveFlushed FlushAll 清空
+	RemoveFlushed
+)
+
+func (r RemoveReason) String() string {
+	switch r {
+	case RemoveExpired:
+		return "expired"
+	case RemoveEvicted:
+		return "evicted"
+	case RemoveDeleted:
+		return "deleted"
+	case RemoveFlushed:
+		return "flushed"
+	default:
+		return "none"
+	}
+}
+
+// RemoveCallback value 为写入时的值，集合类型分别为 map[string]interface{}、[]interface{}、[]Z
+type RemoveCallback func(key string, value interface{}, reason RemoveReason)
+
+type removeEvent struct {
+	key    string
+	val    WrapValue
+	reason RemoveReason
+}
+
+// recordLocked 记录删除事件并计入统计，调用方持有分片写锁
+func (mem *MemCache) recordLocked(sh *memShard, key string, val WrapValue, reason RemoveReason) {
+	if reason == removeSilent {
+		return
+	}
+	if val.Expired() {
+		reason = RemoveExpired
+	}
+	mem.stats.removed(reason)
+	if !mem.hasCallback() {
+		return
+	}
+	sh.events = append(sh.events, removeEvent{key: key, val: val, reason: reason})
+}
+
+func (mem *MemCache) hasCallback() bool {
+	return mem.onExpire != nil || mem.onEvict != nil || mem.onDelete != nil
+}
+
+// unlock 释放分片写锁，然后执行期间记录的回调
+func (mem *MemCache) unlock(sh *memShard) {
+	events := sh.events
+	sh.events = nil
+	sh.rwMutex.Unlock()
+	mem.dispatch(events)
+}
+
+// unlockShards 释放所有分片的写锁后再执行回调，避免回调时仍持有其他分片的锁
+func (mem *MemCache) unlockShards(shards []*memShard) {
+	var events []removeEvent
+	for _, sh := range shards {
+		events = append(events, sh.events...)
+		sh.events = nil
+		sh.rwMutex.Unlock()
+	}
+	mem.dispatch(events)
+}
+
+func (mem *MemCache) dispatch(events []removeEvent) {
+	for _, e := range events {
+		var fn RemoveCallback
+		switch e.reason {
+		case RemoveExpired:
+			fn = mem.onExpire
+		case RemoveEvicted:
+			fn = mem.onEvict
+		case RemoveDeleted, RemoveFlushed:
+			fn = mem.onDelete
+		}
+		if fn != nil {
+			fn(e.key, exportValue(e.val.Value), e.reason)
+		}
+	}
+}
+
+// exportValue 集合类型转换为公开的类型，已经从 store 中删除，不需要加锁
+func exportValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *memList:
+		return v.values(0, v.ll.Len()-1)
+	case *zset:
+		return v.rangeByRank(0, -1)
+	}
+	return value
+}
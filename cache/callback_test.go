@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type removedKey struct {
+	key    string
+	value  interface{}
+	reason RemoveReason
+}
+
+type removeRecorder struct {
+	mutex   sync.Mutex
+	removed []removedKey
+}
+
+func (r *removeRecorder) record(key string, value interface{}, reason RemoveReason) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.removed = append(r.removed, removedKey{key: key, value: value, reason: reason})
+}
+
+// take 返回按 key 排序的记录并清空
+func (r *removeRecorder) take() []removedKey {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	removed := r.removed
+	r.removed = nil
+	sort.Slice(removed, func(i, j int) bool { return removed[i].key < removed[j].key })
+	return removed
+}
+
+func TestMemCache_RemoveCallback(t *testing.T) {
+	r := &removeRecorder{}
+	opt := NewDefaultOptions()
+	// 每个 key 占 9 byte，可以存 3 个
+	opt.Size = 27
+	opt.Eviction = EvictionLRU
+	opt.OnExpire = r.record
+	opt.OnEvict = r.record
+	var memCache *MemCache
+	// 回调中可以继续读写 cache，不会死锁
+	opt.OnDelete = func(key string, value interface{}, reason RemoveReason) {
+		r.record(key, value, reason)
+		memCache.Get(key)
+		memCache.Keys("")
+	}
+	memCache = NewMemCache(opt)
+
+	memCache.Set("a", 1, -1)
+	memCache.Set("b", 2, -1)
+	memCache.Set("c", 3, -1)
+	memCache.Get("a")
+	memCache.Get("b")
+	// 淘汰最久未访问的 c
+	memCache.Set("d", 4, -1)
+	if removed := r.take(); len(removed) != 1 || removed[0] != (removedKey{"c", 3, RemoveEvicted}) {
+		t.Fatal(removed)
+	}
+
+	memCache.Delete("a")
+	memCache.MDelete("b", "missing")
+	if removed := r.take(); len(removed) != 2 ||
+		removed[0] != (removedKey{"a", 1, RemoveDeleted}) || removed[1] != (removedKey{"b", 2, RemoveDeleted}) {
+		t.Fatal(removed)
+	}
+
+	memCache.Set("e", 5, time.Millisecond)
+	memCache.Set("f", 6, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	memCache.Get("e")
+	memCache.Set("f", 7, -1)
+	if removed := r.take(); len(removed) != 2 ||
+		removed[0] != (removedKey{"e", 5, RemoveExpired}) || removed[1] != (removedKey{"f", 6, RemoveExpired}) {
+		t.Fatal(removed)
+	}
+
+	memCache.RPush("l", "x")
+	memCache.FlushAll()
+	removed := r.take()
+	if len(removed) != 3 || removed[0].key != "d" || removed[0].reason != RemoveFlushed || removed[2].key != "l" {
+		t.Fatal(removed)
+	}
+	if values, ok := removed[2].value.([]interface{}); !ok || len(values) != 1 || values[0] != "x" {
+		t.Fatalf("%#v", removed[2].value)
+	}
+
+	// 集合元素全部删除不触发回调
+	memCache.RPush("l", "y")
+	memCache.LPop("l")
+	if removed := r.take(); len(removed) != 0 {
+		t.Fatal(removed)
+	}
+}
+
+func TestMemCache_ExpireCallback(t *testing.T) {
+	var mutex sync.Mutex
+	expired := make([]string, 0)
+	opt := NewDefaultOptions()
+	opt.OnExpire = func(key string, value interface{}, reason RemoveReason) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		expired = append(expired, key+":"+reason.String())
+	}
+	memCache := NewMemCache(opt)
+	memCache.Set("a", 1, time.Millisecond)
+	memCache.SetWithTags("b", 1, time.Millisecond, "t")
+	memCache.Set("c", 1, -1)
+	time.Sleep(5 * time.Millisecond)
+	memCache.cleanExpired(time.Second)
+	memCache.InvalidateTag("t")
+	sort.Strings(expired)
+	if len(expired) != 2 || expired[0] != "a:expired" || expired[1] != "b:expired" {
+		t.Fatal(expired)
+	}
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"time"
+)
+
+/*
+	主动清理过期的 key，与 redis 的 active expire 类似
+Notice:
+	1. 每个分片单独记录带有过期时间的 key，每次随机抽取 cleanSampleSize 个检查，删除其中已过期的
+	2. 抽样中过期的比例超过 1/4 时继续抽样，否则检查下一个分片，每次清理最多占用 CleanInterval 的 1/4
+	3. 每次抽样只持有一个分片的写锁，不会遍历整个分片
+	4. 不开启时过期的 key 在读写时才删除，只写入不读取的 key 会一直占用容量
+	5. 开启 ExpireWheel 时由时间轮删除过期的 key，不再抽样清理
+*/
+
+const (
+	// DefaultCleanInterval AutoClean 为 true 且没有设置 CleanInterval 时使用
+	DefaultCleanInterval = 100 * time.Millisecond
+	// cleanSampleSize 每次抽样的 key 数量
+	cleanSampleSize = 20
+)
+
+// volatileLocked 更新带有过期时间的 key 的索引，调用方持有分片写锁
+func (sh *memShard) volatileLocked(key string, val WrapValue) {
+	if val.Persistent() {
+		delete(sh.volatile, key)
+		return
+	}
+	sh.volatile[key] = struct{}{}
+}
+
+func (mem *MemCache) startAutoClean(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	mem.wg.Add(1)
+	go mem.autoClean(interval)
+}
+
+func (mem *MemCache) autoClean(interval time.Duration) {
+	defer mem.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			mem.cleanExpired(interval / 4)
+		case <-mem.closing:
+			return
+		}
+	}
+}
+
+// cleanExpired 依次清理每个分片，超过 budget 时停止，下次从停止的分片开始
+func (mem *MemCache) cleanExpired(budget time.Duration) {
+	start := time.Now()
+	for i := 0; i < len(mem.shards); i++ {
+		sh := mem.shards[mem.cleanNext]
+		for {
+			sampled, expired := mem.sampleExpired(sh)
+			if time.Since(start) > budget {
+				return
+			}
+			if expired*4 <= sampled {
+				break
+			}
+		}
+		mem.cleanNext = (mem.cleanNext + 1) % len(mem.shards)
+	}
+}
+
+// sampleExpired 随机检查分片内带有过期时间的 key，返回检查和删除的数量
+func (mem *MemCache) sampleExpired(sh *memShard) (sampled, expired int) {
+	sh.rwMutex.Lock()
+	defer mem.unlock(sh)
+	now := time.Now()
+	// map 的遍历从随机的位置开始
+	for key := range sh.volatile {
+		if sampled >= cleanSampleSize {
+			break
+		}
+		sampled++
+		if val := sh.store[key]; !val.Persistent() && val.ExpiredTime.Before(now) {
+			mem.removeLocked(sh, key, val, RemoveExpired)
+			expired++
+		}
+	}
+	return sampled, expired
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemCache_CleanInterval(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Shards = 4
+	opt.CleanInterval = 10 * time.Millisecond
+	memCache := NewMemCache(opt)
+	for i := 0; i < 1000; i++ {
+		memCache.Set(fmt.Sprintf("expired:%d", i), i, 20*time.Millisecond)
+	}
+	for i := 0; i < 100; i++ {
+		memCache.Set(fmt.Sprintf("persistent:%d", i), i, -1)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for memCache.Stats().Val().Keys != 100 {
+		if time.Now().After(deadline) {
+			t.Fatal(memCache.Stats().Val().Keys)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := memCache.Stats().Val().Expirations; n != 1000 {
+		t.Fatal(n)
+	}
+
+	// Close 之后不再清理
+	if err := memCache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	memCache.Set("k1", 1, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if n := memCache.Stats().Val().Keys; n != 101 {
+		t.Fatal(n)
+	}
+}
+
+func TestMemCache_CleanVolatile(t *testing.T) {
+	memCache := NewMemCache()
+	memCache.Set("k1", 1, time.Millisecond)
+	memCache.Set("k2", 2, -1)
+	memCache.Set("k3", 3, time.Millisecond)
+	memCache.Persist("k3")
+	memCache.Expire("k2", time.Millisecond)
+	if n := len(memCache.shards[0].volatile); n != 2 {
+		t.Fatal(n)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	memCache.cleanExpired(time.Second)
+	if keys := memCache.Keys("").Val(); len(keys) != 1 || keys[0] != "k3" {
+		t.Fatal(keys)
+	}
+	if n := len(memCache.shards[0].volatile); n != 0 {
+		t.Fatal(n)
+	}
+}
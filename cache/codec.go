@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+/*
+	值的编码方式
+Notice:
+	1. 快照和追加日志的文件头记录 Codec 的名称，修改配置后仍然按文件头的 Codec 读取
+	2. 基础类型和 RegisterType 注册过的类型会记录类型名，加载后还原为相同的类型
+	3. 没有注册的类型按 interface{} 编码，JSON 加载后数字变成 float64，结构体变成 map[string]interface{}，
+	   gob 需要先通过 RegisterType 或 gob.Register 注册
+	4. 集合类型中的元素按 interface{} 编码
+	5. 只用于 MemCache 的快照和追加日志，RedisCache 固定按 Cmd.Bytes 的方式编码，不使用 Codec
+*/
+
+// Codec 值的编码方式
+type Codec interface {
+	// Name 记录在文件头中，加载时按名称找到对应的 Codec
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSONCodec 默认的编码方式
+	JSONCodec Codec = jsonCodec{}
+	// GobCodec 可以还原注册过的类型
+	GobCodec Codec = gobCodec{}
+	// RawCodec 只支持 string 和 []byte，适合已经序列化好的值
+	RawCodec Codec = rawCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string {
+	return "gob"
+}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Name() string {
+	return "raw"
+}
+
+func (c rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch d := v.(type) {
+	case []byte:
+		return d, nil
+	case string:
+		return []byte(d), nil
+	case *[]byte:
+		return *d, nil
+	case *string:
+		return []byte(*d), nil
+	case *interface{}:
+		return c.Marshal(*d)
+	}
+	return nil, fmt.Errorf("cache: raw codec can't marshal %T", v)
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch d := v.(type) {
+	case *[]byte:
+		*d = append([]byte{}, data...)
+	case *string:
+		*d = string(data)
+	case *interface{}:
+		*d = append([]byte{}, data...)
+	default:
+		return fmt.Errorf("cache: raw codec can't unmarshal into %T", v)
+	}
+	return nil
+}
+
+var (
+	codecsMutex sync.RWMutex
+	codecs      = map[string]Codec{}
+
+	typesMutex sync.RWMutex
+	typeByName = map[string]reflect.Type{}
+	nameByType = map[reflect.Type]string{}
+)
+
+func init() {
+	for _, c := range []Codec{JSONCodec, GobCodec, RawCodec} {
+		RegisterCodec(c)
+	}
+	for _, value := range []interface{}{
+		"", []byte{}, false,
+		int(0), int8(0), int16(0), int32(0), int64(0),
+		uint(0), uint8(0), uint16(0), uint32(0), uint64(0),
+		float32(0), float64(0), time.Time{},
+	} {
+		RegisterType(value)
+	}
+}
+
+// RegisterCodec 注册其他的编码方式，例如 msgpack，加载文件时按名称查找
+func RegisterCodec(c Codec) {
+	codecsMutex.Lock()
+	defer codecsMutex.Unlock()
+	codecs[c.Name()] = c
+}
+
+func codecByName(name string) (Codec, error) {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("cache: unknown codec %q", name)
+	}
+	return c, nil
+}
+
+// RegisterType 注册值的类型，保存时记录类型名，加载后还原为相同的类型
+// 同时以相同的名称注册到 gob，不需要再调用 gob.Register
+func RegisterType(value interface{}) {
+	t := reflect.TypeOf(value)
+	name := typeKey(t)
+	typesMutex.Lock()
+	typeByName[name] = t
+	nameByType[t] = name
+	// 之前的版本按 t.String() 记录类型名，保留用于加载旧文件，重名时以先注册的为准
+	if _, ok := typeByName[t.String()]; !ok {
+		typeByName[t.String()] = t
+	}
+	typesMutex.Unlock()
+	gob.RegisterName(name, value)
+}
+
+// typeKey 命名类型使用完整的包路径，避免不同包中的同名类型冲突，基础类型和未命名的类型使用 t.String()
+func typeKey(t reflect.Type) string {
+	if t.PkgPath() == "" || t.Name() == "" {
+		return t.String()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+func typeName(value interface{}) (string, bool) {
+	typesMutex.RLock()
+	defer typesMutex.RUnlock()
+	name, ok := nameByType[reflect.TypeOf(value)]
+	return name, ok
+}
+
+func typeOf(name string) (reflect.Type, bool) {
+	typesMutex.RLock()
+	defer typesMutex.RUnlock()
+	t, ok := typeByName[name]
+	return t, ok
+}
+
+// encodedValue 编码后的 WrapValue
+type encodedValue struct {
+	Value       []byte    `json:"v"`
+	Type        string    `json:"type,omitempty"`
+	ExpiredTime time.Time `json:"e"`
+	Size        int64     `json:"s"`
+	Kind        ValueKind `json:"k,omitempty"`
+	Tags        []string  `json:"t,omitempty"`
+}
+
+// encodeValue 调用方持有分片锁，集合类型转换为公开的类型后编码
+func encodeValue(codec Codec, val WrapValue) (*encodedValue, error) {
+	ev := &encodedValue{
+		ExpiredTime: val.ExpiredTime,
+		Size:        val.Size,
+		Kind:        val.Kind,
+		Tags:        val.Tags,
+	}
+	var err error
+	switch {
+	case val.Kind != KindString:
+		ev.Value, err = codec.Marshal(exportValue(val.Value))
+	case val.Value == nil:
+	default:
+		if name, ok := typeName(val.Value); ok {
+			ev.Type = name
+			ev.Value, err = codec.Marshal(val.Value)
+		} else {
+			ev.Value, err = codec.Marshal(&val.Value)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// decodeValue 集合类型还原为内存中的结构
+func decodeValue(codec Codec, ev *encodedValue) (WrapValue, error) {
+	val := WrapValue{
+		ExpiredTime: ev.ExpiredTime,
+		Size:        ev.Size,
+		Kind:        ev.Kind,
+		Tags:        ev.Tags,
+	}
+	var dest reflect.Value
+	switch ev.Kind {
+	case KindString:
+		if ev.Type == "" && len(ev.Value) == 0 {
+			return val, nil
+		}
+		if ev.Type == "" {
+			dest = reflect.New(reflect.TypeOf((*interface{})(nil)).Elem())
+			break
+		}
+		t, ok := typeOf(ev.Type)
+		if !ok {
+			return val, fmt.Errorf("cache: type %q not registered", ev.Type)
+		}
+		dest = reflect.New(t)
+	case KindHash:
+		dest = reflect.ValueOf(&map[string]interface{}{})
+	case KindList:
+		dest = reflect.ValueOf(&[]interface{}{})
+	case KindZSet:
+		dest = reflect.ValueOf(&[]Z{})
+	default:
+		return val, fmt.Errorf("cache: unknown value kind %q", ev.Kind)
+	}
+	if err := codec.Unmarshal(ev.Value, dest.Interface()); err != nil {
+		return val, err
+	}
+	val.Value = dest.Elem().Interface()
+	restoreValue(&val)
+	return val, nil
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	htmltemplate "html/template"
+	"os"
+	"reflect"
+	"testing"
+	texttemplate "text/template"
+	"time"
+)
+
+type codecUser struct {
+	ID   int64
+	Name string
+	Tags []string
+}
+
+func init() {
+	RegisterType(codecUser{})
+}
+
+func TestCodec_Snapshot(t *testing.T) {
+	type unregistered struct {
+		Name string
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	user := codecUser{ID: 1, Name: "tom", Tags: []string{"a"}}
+
+	for _, codec := range []Codec{JSONCodec, GobCodec} {
+		opt := NewDefaultOptions()
+		opt.Filename = "./cache_codec.bak"
+		opt.Codec = codec
+		memCache := NewMemCache(opt)
+		memCache.Set("int", 1, -1)
+		memCache.Set("uint8", uint8(2), -1)
+		memCache.Set("bytes", []byte("raw"), -1)
+		memCache.Set("time", now, -1)
+		memCache.Set("user", user, -1)
+		memCache.Set("nil", nil, -1)
+		memCache.HSet("hash", "f", "v")
+		memCache.RPush("list", "a", "b")
+		memCache.ZAdd("zset", Z{Score: 1, Member: "m"})
+		if codec == JSONCodec {
+			memCache.Set("unregistered", unregistered{Name: "jerry"}, -1)
+		}
+		if err := memCache.Close(); err != nil {
+			t.Fatal(codec.Name(), err)
+		}
+
+		// 修改配置后仍然按文件头的 Codec 读取
+		opt.Codec = RawCodec
+		loaded := NewMemCache(opt)
+		os.Remove(opt.Filename)
+
+		expected := map[string]interface{}{
+			"int":   1,
+			"uint8": uint8(2),
+			"bytes": []byte("raw"),
+			"time":  now,
+			"user":  user,
+			"nil":   nil,
+		}
+		for key, value := range expected {
+			getCmd := loaded.Get(key)
+			if !getCmd.Exists() || !reflect.DeepEqual(getCmd.Val(), value) {
+				t.Fatalf("%s %s: %#v != %#v", codec.Name(), key, getCmd.Val(), value)
+			}
+		}
+		if getCmd := loaded.HGet("hash", "f"); getCmd.Val() != "v" {
+			t.Fatal(codec.Name(), getCmd.Val())
+		}
+		if rangeCmd := loaded.LRange("list", 0, -1); len(rangeCmd.Val()) != 2 || rangeCmd.Val()[1] != "b" {
+			t.Fatal(codec.Name(), rangeCmd.Val())
+		}
+		if scoreCmd := loaded.ZScore("zset", "m"); scoreCmd.Val() != 1 {
+			t.Fatal(codec.Name(), scoreCmd.Val())
+		}
+		if codec == JSONCodec {
+			// 没有注册的类型变成 map
+			var u unregistered
+			if getCmd := loaded.Get("unregistered"); getCmd.Scan(&u) != nil || u.Name != "jerry" {
+				t.Fatalf("%#v", getCmd.Val())
+			}
+		}
+	}
+}
+
+func TestCodec_Raw(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Filename = "./cache_codec_raw.bak"
+	opt.Codec = RawCodec
+	defer os.Remove(opt.Filename)
+	memCache := NewMemCache(opt)
+	memCache.Set("s", "string", -1)
+	memCache.Set("b", []byte("bytes"), -1)
+	if err := memCache.Save().Error(); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewMemCache(opt)
+	if getCmd := loaded.Get("s"); getCmd.Val() != "string" {
+		t.Fatal(getCmd.Val())
+	}
+	if getCmd := loaded.Get("b"); getCmd.ValString() != "bytes" {
+		t.Fatal(getCmd.Val())
+	}
+
+	// 只支持 string 和 []byte
+	memCache.Set("n", 1, -1)
+	if err := memCache.Save().Error(); err == nil {
+		t.Fatal("raw codec should not encode int")
+	}
+}
+
+func TestCodec_RegisterSameName(t *testing.T) {
+	// 两个类型的 String() 都是 template.Template
+	RegisterType(texttemplate.Template{})
+	RegisterType(htmltemplate.Template{})
+	for _, value := range []interface{}{texttemplate.Template{}, htmltemplate.Template{}} {
+		name, ok := typeName(value)
+		if !ok {
+			t.Fatal(reflect.TypeOf(value))
+		}
+		if typ, ok := typeOf(name); !ok || typ != reflect.TypeOf(value) {
+			t.Fatal(name, typ)
+		}
+	}
+
+	// 基础类型的名称不变，之前版本记录的类型名仍然可以加载
+	if name, _ := typeName(time.Time{}); name != "time.Time" {
+		t.Fatal(name)
+	}
+	if typ, ok := typeOf("cache.codecUser"); !ok || typ != reflect.TypeOf(codecUser{}) {
+		t.Fatal(typ)
+	}
+}
+
+func TestCodec_AppendOnly(t *testing.T) {
+	opt := newAOFOptions("./cache_codec_aof.bak")
+	opt.Codec = GobCodec
+	defer removeAOFFiles(opt.Filename)
+	memCache := NewMemCache(opt)
+	memCache.Set("user", codecUser{ID: 1}, -1)
+	crash(t, memCache)
+
+	// 切换 Codec 后继续写入同一个日志
+	opt.Codec = JSONCodec
+	memCache = NewMemCache(opt)
+	memCache.Set("n", int64(2), -1)
+	crash(t, memCache)
+
+	memCache = NewMemCache(opt)
+	defer memCache.Close()
+	if getCmd := memCache.Get("user"); !reflect.DeepEqual(getCmd.Val(), codecUser{ID: 1}) {
+		t.Fatalf("%#v", getCmd.Val())
+	}
+	if getCmd := memCache.Get("n"); getCmd.Val() != int64(2) {
+		t.Fatalf("%#v", getCmd.Val())
+	}
+}
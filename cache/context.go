@@ -0,0 +1,304 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// ContextCache 与 Cache 相同的操作，第一个参数为 ctx，用于传递超时、取消和链路追踪
+// 方法名增加 Context 后缀，同一个类型可以同时实现 Cache 和 ContextCache
+type ContextCache interface {
+	GetContext(ctx context.Context, key string) *Cmd
+	SetContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *StatusCmd
+	KeysContext(ctx context.Context, prefix string) *SliceStringCmd
+	ScanContext(ctx context.Context, cursor uint64, pattern string, count int64) *ScanCmd
+	DeleteContext(ctx context.Context, key string) *StatusCmd
+
+	ExpireContext(ctx context.Context, key string, ttl time.Duration) *BoolCmd
+	ExpireAtContext(ctx context.Context, key string, tm time.Time) *BoolCmd
+	PersistContext(ctx context.Context, key string) *BoolCmd
+	TouchContext(ctx context.Context, key string, ttl time.Duration) *BoolCmd
+
+	IncrByContext(ctx context.Context, key string, value int64, ttl time.Duration) *IntCmd
+	DecrByContext(ctx context.Context, key string, value int64, ttl time.Duration) *IntCmd
+	IncrByFloatContext(ctx context.Context, key string, value float64, ttl time.Duration) *FloatCmd
+
+	SetNXContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *BoolCmd
+	SetXXContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *BoolCmd
+	GetSetContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *Cmd
+	CompareAndSwapContext(ctx context.Context, key string, old, new interface{}, ttl time.Duration) *BoolCmd
+
+	MGetContext(ctx context.Context, keys ...string) *MultiCmd
+	MSetContext(ctx context.Context, values map[string]interface{}, ttl time.Duration) *StatusCmd
+	MDeleteContext(ctx context.Context, keys ...string) *IntCmd
+
+	FlushAllContext(ctx context.Context) *StatusCmd
+	SaveContext(ctx context.Context) *StatusCmd
+
+	Close() error
+}
+
+// WithContext 将 Cache 转换为 ContextCache
+// c 已经实现了 ContextCache 时直接返回，否则每次调用前检查 ctx 是否已经结束
+func WithContext(c Cache) ContextCache {
+	if cc, ok := c.(ContextCache); ok {
+		return cc
+	}
+	return &contextCache{c: c}
+}
+
+// WithoutContext 将 ContextCache 转换为 Cache，使用 context.Background()
+// c 已经实现了 Cache 时直接返回
+func WithoutContext(c ContextCache) Cache {
+	if cc, ok := c.(Cache); ok {
+		return cc
+	}
+	return &backgroundCache{c: c}
+}
+
+type contextCache struct {
+	c Cache
+}
+
+func (cc *contextCache) GetContext(ctx context.Context, key string) *Cmd {
+	if err := ctx.Err(); err != nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.Get(key)
+}
+
+func (cc *contextCache) SetContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *StatusCmd {
+	if err := ctx.Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.Set(key, value, ttl)
+}
+
+func (cc *contextCache) KeysContext(ctx context.Context, prefix string) *SliceStringCmd {
+	if err := ctx.Err(); err != nil {
+		return &SliceStringCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.Keys(prefix)
+}
+
+func (cc *contextCache) ScanContext(ctx context.Context, cursor uint64, pattern string, count int64) *ScanCmd {
+	if err := ctx.Err(); err != nil {
+		return &ScanCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.Scan(cursor, pattern, count)
+}
+
+func (cc *contextCache) DeleteContext(ctx context.Context, key string) *StatusCmd {
+	if err := ctx.Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.Delete(key)
+}
+
+func (cc *contextCache) ExpireContext(ctx context.Context, key string, ttl time.Duration) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.Expire(key, ttl)
+}
+
+func (cc *contextCache) ExpireAtContext(ctx context.Context, key string, tm time.Time) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.ExpireAt(key, tm)
+}
+
+func (cc *contextCache) PersistContext(ctx context.Context, key string) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.Persist(key)
+}
+
+func (cc *contextCache) TouchContext(ctx context.Context, key string, ttl time.Duration) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.Touch(key, ttl)
+}
+
+func (cc *contextCache) IncrByContext(ctx context.Context, key string, value int64, ttl time.Duration) *IntCmd {
+	if err := ctx.Err(); err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.IncrBy(key, value, ttl)
+}
+
+func (cc *contextCache) DecrByContext(ctx context.Context, key string, value int64, ttl time.Duration) *IntCmd {
+	if err := ctx.Err(); err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.DecrBy(key, value, ttl)
+}
+
+func (cc *contextCache) IncrByFloatContext(ctx context.Context, key string, value float64, ttl time.Duration) *FloatCmd {
+	if err := ctx.Err(); err != nil {
+		return &FloatCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.IncrByFloat(key, value, ttl)
+}
+
+func (cc *contextCache) SetNXContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.SetNX(key, value, ttl)
+}
+
+func (cc *contextCache) SetXXContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.SetXX(key, value, ttl)
+}
+
+func (cc *contextCache) GetSetContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *Cmd {
+	if err := ctx.Err(); err != nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.GetSet(key, value, ttl)
+}
+
+func (cc *contextCache) CompareAndSwapContext(ctx context.Context, key string, old, new interface{}, ttl time.Duration) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.CompareAndSwap(key, old, new, ttl)
+}
+
+func (cc *contextCache) MGetContext(ctx context.Context, keys ...string) *MultiCmd {
+	if err := ctx.Err(); err != nil {
+		return &MultiCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.MGet(keys...)
+}
+
+func (cc *contextCache) MSetContext(ctx context.Context, values map[string]interface{}, ttl time.Duration) *StatusCmd {
+	if err := ctx.Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.MSet(values, ttl)
+}
+
+func (cc *contextCache) MDeleteContext(ctx context.Context, keys ...string) *IntCmd {
+	if err := ctx.Err(); err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.MDelete(keys...)
+}
+
+func (cc *contextCache) FlushAllContext(ctx context.Context) *StatusCmd {
+	if err := ctx.Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.FlushAll()
+}
+
+func (cc *contextCache) SaveContext(ctx context.Context) *StatusCmd {
+	if err := ctx.Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return cc.c.Save()
+}
+
+func (cc *contextCache) Close() error {
+	return cc.c.Close()
+}
+
+type backgroundCache struct {
+	c ContextCache
+}
+
+func (bc *backgroundCache) Get(key string) *Cmd {
+	return bc.c.GetContext(context.Background(), key)
+}
+
+func (bc *backgroundCache) Set(key string, value interface{}, ttl time.Duration) *StatusCmd {
+	return bc.c.SetContext(context.Background(), key, value, ttl)
+}
+
+func (bc *backgroundCache) Keys(prefix string) *SliceStringCmd {
+	return bc.c.KeysContext(context.Background(), prefix)
+}
+
+func (bc *backgroundCache) Scan(cursor uint64, pattern string, count int64) *ScanCmd {
+	return bc.c.ScanContext(context.Background(), cursor, pattern, count)
+}
+
+func (bc *backgroundCache) Delete(key string) *StatusCmd {
+	return bc.c.DeleteContext(context.Background(), key)
+}
+
+func (bc *backgroundCache) Expire(key string, ttl time.Duration) *BoolCmd {
+	return bc.c.ExpireContext(context.Background(), key, ttl)
+}
+
+func (bc *backgroundCache) ExpireAt(key string, tm time.Time) *BoolCmd {
+	return bc.c.ExpireAtContext(context.Background(), key, tm)
+}
+
+func (bc *backgroundCache) Persist(key string) *BoolCmd {
+	return bc.c.PersistContext(context.Background(), key)
+}
+
+func (bc *backgroundCache) Touch(key string, ttl time.Duration) *BoolCmd {
+	return bc.c.TouchContext(context.Background(), key, ttl)
+}
+
+func (bc *backgroundCache) IncrBy(key string, value int64, ttl time.Duration) *IntCmd {
+	return bc.c.IncrByContext(context.Background(), key, value, ttl)
+}
+
+func (bc *backgroundCache) DecrBy(key string, value int64, ttl time.Duration) *IntCmd {
+	return bc.c.DecrByContext(context.Background(), key, value, ttl)
+}
+
+func (bc *backgroundCache) IncrByFloat(key string, value float64, ttl time.Duration) *FloatCmd {
+	return bc.c.IncrByFloatContext(context.Background(), key, value, ttl)
+}
+
+func (bc *backgroundCache) SetNX(key string, value interface{}, ttl time.Duration) *BoolCmd {
+	return bc.c.SetNXContext(context.Background(), key, value, ttl)
+}
+
+func (bc *backgroundCache) SetXX(key string, value interface{}, ttl time.Duration) *BoolCmd {
+	return bc.c.SetXXContext(context.Background(), key, value, ttl)
+}
+
+func (bc *backgroundCache) GetSet(key string, value interface{}, ttl time.Duration) *Cmd {
+	return bc.c.GetSetContext(context.Background(), key, value, ttl)
+}
+
+func (bc *backgroundCache) CompareAndSwap(key string, old, new interface{}, ttl time.Duration) *BoolCmd {
+	return bc.c.CompareAndSwapContext(context.Background(), key, old, new, ttl)
+}
+
+func (bc *backgroundCache) MGet(keys ...string) *MultiCmd {
+	return bc.c.MGetContext(context.Background(), keys...)
+}
+
+func (bc *backgroundCache) MSet(values map[string]interface{}, ttl time.Duration) *StatusCmd {
+	return bc.c.MSetContext(context.Background(), values, ttl)
+}
+
+func (bc *backgroundCache) MDelete(keys ...string) *IntCmd {
+	return bc.c.MDeleteContext(context.Background(), keys...)
+}
+
+func (bc *backgroundCache) FlushAll() *StatusCmd {
+	return bc.c.FlushAllContext(context.Background())
+}
+
+func (bc *backgroundCache) Save() *StatusCmd {
+	return bc.c.SaveContext(context.Background())
+}
+
+func (bc *backgroundCache) Close() error {
+	return bc.c.Close()
+}
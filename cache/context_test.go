@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+var (
+	_ ContextCache = (*MemCache)(nil)
+	_ ContextCache = (*RedisCache)(nil)
+)
+
+// onlyCache 只实现了 Cache
+type onlyCache struct {
+	Cache
+}
+
+func TestWithContext(t *testing.T) {
+	memCache := NewMemCache(NewDefaultOptions())
+	if WithContext(memCache) != ContextCache(memCache) {
+		t.Fatal("MemCache should be used directly")
+	}
+
+	cc := WithContext(onlyCache{memCache})
+	ctx := context.Background()
+	if setCmd := cc.SetContext(ctx, "k", "v", time.Minute); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if getCmd := cc.GetContext(ctx, "k"); getCmd.ValString() != "v" {
+		t.Fatal(getCmd.ValString())
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if getCmd := cc.GetContext(canceled, "k"); getCmd.Error() != context.Canceled {
+		t.Fatal(getCmd.Error())
+	}
+	if setCmd := cc.SetContext(canceled, "k", "v2", time.Minute); setCmd.Error() != context.Canceled {
+		t.Fatal(setCmd.Error())
+	}
+
+	// 再转回 Cache
+	c := WithoutContext(cc)
+	if getCmd := c.Get("k"); getCmd.ValString() != "v" {
+		t.Fatal(getCmd.ValString())
+	}
+	if incrCmd := c.IncrBy("n", 1, -1); incrCmd.Val() != 1 {
+		t.Fatal(incrCmd.Val())
+	}
+}
+
+func TestMemCache_Context(t *testing.T) {
+	memCache := NewMemCache(NewDefaultOptions())
+	for i := 0; i < 3*scanCheckEvery; i++ {
+		memCache.Set(fmt.Sprintf("k%d", i), i, -1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if keysCmd := memCache.KeysContext(ctx, "k"); keysCmd.Error() != nil || len(keysCmd.Val()) != 3*scanCheckEvery {
+		t.Fatal(len(keysCmd.Val()), keysCmd.Error())
+	}
+	cancel()
+	if keysCmd := memCache.KeysContext(ctx, "k"); keysCmd.Error() != context.Canceled {
+		t.Fatal(keysCmd.Error())
+	}
+	if mgetCmd := memCache.MGetContext(ctx, "k1"); mgetCmd.Error() != context.Canceled {
+		t.Fatal(mgetCmd.Error())
+	}
+	if saveCmd := memCache.SaveContext(ctx); saveCmd.Error() != ErrFilenameEmpty {
+		t.Fatal(saveCmd.Error())
+	}
+}
+
+func TestRedisCache_Context(t *testing.T) {
+	rc, _ := newTestRedisCache(t)
+	ctx := context.Background()
+	if setCmd := rc.SetContext(ctx, "k", "v", time.Minute); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if getCmd := rc.GetContext(ctx, "k"); getCmd.ValString() != "v" {
+		t.Fatal(getCmd.ValString())
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if getCmd := rc.GetContext(canceled, "k"); getCmd.Error() == nil {
+		t.Fatal("canceled context should return error")
+	}
+}
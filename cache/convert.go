@@ -0,0 +1,314 @@
+package cache
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+/*
+	值类型转换
+Notice:
+	1. MemCache 保存的是原始类型，使用 JSONCodec 从文件加载后，集合中的数字变成 float64，没有注册的结构体变成 map[string]interface{}
+	2. RedisCache 读取的都是 string
+	三种情况转换结果保持一致
+*/
+
+func conversionError(value interface{}, to string) error {
+	return fmt.Errorf("cache: can't convert %T(%v) to %s", value, value, to)
+}
+
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, ErrNil
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return uintToInt64(uint64(v))
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return uintToInt64(v)
+	case float32:
+		return floatToInt64(float64(v))
+	case float64:
+		return floatToInt64(v)
+	case json.Number:
+		return v.Int64()
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	}
+	return 0, conversionError(value, "int64")
+}
+
+func uintToInt64(v uint64) (int64, error) {
+	if v > math.MaxInt64 {
+		return 0, conversionError(v, "int64")
+	}
+	return int64(v), nil
+}
+
+func floatToInt64(v float64) (int64, error) {
+	if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+		return 0, conversionError(v, "int64")
+	}
+	return int64(v), nil
+}
+
+func toUint64(value interface{}) (uint64, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, ErrNil
+	case uint:
+		return uint64(v), nil
+	case uint8:
+		return uint64(v), nil
+	case uint16:
+		return uint64(v), nil
+	case uint32:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	case float32, float64:
+		f, _ := toFloat64(v)
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+			return 0, conversionError(value, "uint64")
+		}
+		return uint64(f), nil
+	case string:
+		return strconv.ParseUint(v, 10, 64)
+	case []byte:
+		return strconv.ParseUint(string(v), 10, 64)
+	case json.Number:
+		return strconv.ParseUint(string(v), 10, 64)
+	}
+	i, err := toInt64(value)
+	if err != nil || i < 0 {
+		return 0, conversionError(value, "uint64")
+	}
+	return uint64(i), nil
+}
+
+func toFloat64(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, ErrNil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case []byte:
+		return strconv.ParseFloat(string(v), 64)
+	case uint, uint64:
+		u, _ := toUint64(v)
+		return float64(u), nil
+	}
+	i, err := toInt64(value)
+	if err != nil {
+		return 0, conversionError(value, "float64")
+	}
+	return float64(i), nil
+}
+
+// toBool 数字非 0 为 true，字符串按 strconv.ParseBool 解析，兼容 redis 存储的 "1"/"0"
+func toBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, ErrNil
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(v)
+	case []byte:
+		return strconv.ParseBool(string(v))
+	}
+	f, err := toFloat64(value)
+	if err != nil {
+		return false, conversionError(value, "bool")
+	}
+	return f != 0, nil
+}
+
+// toTime 字符串按 RFC3339Nano 解析
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, ErrNil
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		return *v, nil
+	case string:
+		return time.Parse(time.RFC3339Nano, v)
+	case []byte:
+		return time.Parse(time.RFC3339Nano, string(v))
+	}
+	return time.Time{}, conversionError(value, "time.Time")
+}
+
+// toBytes 与写入 redis 时的编码一致，非基础类型编码为 JSON
+func toBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, ErrNil
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	case bool:
+		if v {
+			return []byte("1"), nil
+		}
+		return []byte("0"), nil
+	case int, int8, int16, int32, int64:
+		i, _ := toInt64(v)
+		return strconv.AppendInt(nil, i, 10), nil
+	case uint, uint8, uint16, uint32, uint64:
+		u, _ := toUint64(v)
+		return strconv.AppendUint(nil, u, 10), nil
+	case float32:
+		return strconv.AppendFloat(nil, float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.AppendFloat(nil, v, 'f', -1, 64), nil
+	case time.Time:
+		return []byte(v.Format(time.RFC3339Nano)), nil
+	case encoding.BinaryMarshaler:
+		return v.MarshalBinary()
+	}
+	return json.Marshal(value)
+}
+
+// valueEqual 值相同，或者编码后相同。从文件加载的 float64(1) 与 int 1 相等，与 redis 比较编码后的字符串一致
+func valueEqual(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	byta, err := toBytes(a)
+	if err != nil {
+		return false
+	}
+	bytb, err := toBytes(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(byta, bytb)
+}
+
+// scanValue 把 value 写入 dest 指针
+func scanValue(value interface{}, dest interface{}) error {
+	if value == nil {
+		return ErrNil
+	}
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("cache: Scan(non-pointer %T)", dest)
+	}
+	elem := rv.Elem()
+
+	// 类型相同直接赋值
+	if vt := reflect.TypeOf(value); vt.AssignableTo(elem.Type()) {
+		elem.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	switch d := dest.(type) {
+	case *string:
+		byt, err := toBytes(value)
+		if err != nil {
+			return err
+		}
+		*d = string(byt)
+		return nil
+	case *[]byte:
+		byt, err := toBytes(value)
+		if err != nil {
+			return err
+		}
+		*d = byt
+		return nil
+	case *time.Time:
+		t, err := toTime(value)
+		if err != nil {
+			return err
+		}
+		*d = t
+		return nil
+	case encoding.BinaryUnmarshaler:
+		byt, err := toBytes(value)
+		if err != nil {
+			return err
+		}
+		return d.UnmarshalBinary(byt)
+	}
+
+	switch elem.Kind() {
+	case reflect.Bool:
+		b, err := toBool(value)
+		if err != nil {
+			return err
+		}
+		elem.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := toInt64(value)
+		if err != nil {
+			return err
+		}
+		if elem.OverflowInt(i) {
+			return conversionError(value, elem.Type().String())
+		}
+		elem.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := toUint64(value)
+		if err != nil {
+			return err
+		}
+		if elem.OverflowUint(u) {
+			return conversionError(value, elem.Type().String())
+		}
+		elem.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat64(value)
+		if err != nil {
+			return err
+		}
+		elem.SetFloat(f)
+		return nil
+	}
+
+	// 其他类型通过 JSON 转换，包括从文件加载的 map[string]interface{} 和 redis 中的 JSON 字符串
+	byt, err := toBytes(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(byt, dest)
+}
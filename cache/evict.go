@@ -0,0 +1,271 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"sync"
+	"time"
+)
+
+// EvictionPolicy 容量不足时的淘汰策略
+type EvictionPolicy int
+
+const (
+	// EvictionNone 不淘汰，超出容量写入失败
+	EvictionNone EvictionPolicy = iota
+	// EvictionLRU 淘汰最久未访问的 key
+	EvictionLRU
+	// EvictionLFU 淘汰访问次数最少的 key，次数相同时淘汰最久未访问的
+	EvictionLFU
+	// EvictionTTL 优先淘汰最先过期的 key
+	EvictionTTL
+)
+
+func (p EvictionPolicy) String() string {
+	switch p {
+	case EvictionLRU:
+		return "lru"
+	case EvictionLFU:
+		return "lfu"
+	case EvictionTTL:
+		return "ttl"
+	default:
+		return "none"
+	}
+}
+
+// evictor 记录 key 的访问情况，选出淘汰的 key
+// Get 只持有读锁，所以 evictor 自己加锁
+type evictor interface {
+	// add 新增或覆盖 key
+	add(key string, val WrapValue)
+	// access 读取了 key，key 不存在时忽略
+	access(key string)
+	// expire 修改了 key 的过期时间，不算作访问
+	expire(key string, val WrapValue)
+	remove(key string)
+	// victim 下一个应被淘汰的 key
+	victim() (string, bool)
+	reset()
+}
+
+func newEvictor(policy EvictionPolicy) evictor {
+	switch policy {
+	case EvictionLRU:
+		return newLRUEvictor()
+	case EvictionLFU:
+		return newLFUEvictor()
+	case EvictionTTL:
+		return newTTLEvictor()
+	default:
+		return nil
+	}
+}
+
+type lruEvictor struct {
+	mutex sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newLRUEvictor() *lruEvictor {
+	return &lruEvictor{
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (e *lruEvictor) add(key string, _ WrapValue) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if elem, ok := e.items[key]; ok {
+		e.ll.MoveToFront(elem)
+		return
+	}
+	e.items[key] = e.ll.PushFront(key)
+}
+
+func (e *lruEvictor) access(key string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if elem, ok := e.items[key]; ok {
+		e.ll.MoveToFront(elem)
+	}
+}
+
+func (e *lruEvictor) expire(string, WrapValue) {}
+
+func (e *lruEvictor) remove(key string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if elem, ok := e.items[key]; ok {
+		e.ll.Remove(elem)
+		delete(e.items, key)
+	}
+}
+
+func (e *lruEvictor) victim() (string, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	elem := e.ll.Back()
+	if elem == nil {
+		return "", false
+	}
+	return elem.Value.(string), true
+}
+
+func (e *lruEvictor) reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.ll.Init()
+	e.items = make(map[string]*list.Element)
+}
+
+// heapItem 用于 LFU 和 TTL 的小顶堆
+type heapItem struct {
+	key   string
+	freq  int64
+	seq   int64
+	at    time.Time
+	index int
+}
+
+type itemHeap struct {
+	items []*heapItem
+	less  func(a, b *heapItem) bool
+}
+
+func (h *itemHeap) Len() int { return len(h.items) }
+
+func (h *itemHeap) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+
+func (h *itemHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *itemHeap) Push(x interface{}) {
+	item := x.(*heapItem)
+	item.index = len(h.items)
+	h.items = append(h.items, item)
+}
+
+func (h *itemHeap) Pop() interface{} {
+	n := len(h.items)
+	item := h.items[n-1]
+	h.items[n-1] = nil
+	h.items = h.items[:n-1]
+	return item
+}
+
+// heapEvictor LFU 和 TTL 共用，区别只在排序和更新方式
+type heapEvictor struct {
+	mutex sync.Mutex
+	h     *itemHeap
+	items map[string]*heapItem
+	seq   int64
+	// update 新增、覆盖和访问时更新排序字段，访问时 val 为 nil
+	update func(item *heapItem, val *WrapValue)
+	// byTTL 按过期时间排序，修改过期时间后需要重新排序
+	byTTL bool
+}
+
+func newLFUEvictor() *heapEvictor {
+	e := &heapEvictor{
+		items: make(map[string]*heapItem),
+	}
+	e.h = &itemHeap{less: func(a, b *heapItem) bool {
+		if a.freq != b.freq {
+			return a.freq < b.freq
+		}
+		return a.seq < b.seq
+	}}
+	e.update = func(item *heapItem, _ *WrapValue) {
+		e.seq++
+		item.freq++
+		item.seq = e.seq
+	}
+	return e
+}
+
+func newTTLEvictor() *heapEvictor {
+	e := &heapEvictor{
+		items: make(map[string]*heapItem),
+		byTTL: true,
+	}
+	e.h = &itemHeap{less: func(a, b *heapItem) bool {
+		// 没有过期时间的 key 最后淘汰
+		if a.at.IsZero() || b.at.IsZero() {
+			return !a.at.IsZero()
+		}
+		return a.at.Before(b.at)
+	}}
+	e.update = func(item *heapItem, val *WrapValue) {
+		if val != nil {
+			item.at = val.ExpiredTime
+		}
+	}
+	return e
+}
+
+func (e *heapEvictor) add(key string, val WrapValue) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	item, ok := e.items[key]
+	if !ok {
+		item = &heapItem{key: key}
+		e.update(item, &val)
+		e.items[key] = item
+		heap.Push(e.h, item)
+		return
+	}
+	e.update(item, &val)
+	heap.Fix(e.h, item.index)
+}
+
+func (e *heapEvictor) access(key string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if item, ok := e.items[key]; ok {
+		e.update(item, nil)
+		heap.Fix(e.h, item.index)
+	}
+}
+
+func (e *heapEvictor) expire(key string, val WrapValue) {
+	if !e.byTTL {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if item, ok := e.items[key]; ok {
+		item.at = val.ExpiredTime
+		heap.Fix(e.h, item.index)
+	}
+}
+
+func (e *heapEvictor) remove(key string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if item, ok := e.items[key]; ok {
+		heap.Remove(e.h, item.index)
+		delete(e.items, key)
+	}
+}
+
+func (e *heapEvictor) victim() (string, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.h.Len() == 0 {
+		return "", false
+	}
+	return e.h.items[0].key, true
+}
+
+func (e *heapEvictor) reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.h.items = nil
+	e.items = make(map[string]*heapItem)
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"time"
+)
+
+/*
+	回源加载
+Notice:
+	1. 同一个 key 并发未命中时只调用一次 loader，其他调用方共享结果
+	2. loader 返回 ErrNil 表示数据不存在，NegativeTTL > 0 时缓存该结果，避免不存在的 key 反复回源
+	3. loader 返回其他错误时不缓存，直接返回给调用方
+*/
+
+// negativeValue 数据不存在时写入缓存的占位值
+const negativeValue = "\x00gokit/cache:negative"
+
+// LoaderFunc 加载 key 对应的值，数据不存在时返回 ErrNil
+type LoaderFunc func(key string) (interface{}, error)
+
+type LoaderOptions struct {
+	// NegativeTTL 数据不存在时缓存的时间，<= 0 不缓存
+	NegativeTTL time.Duration
+}
+
+// Loader 在任意 Cache 上提供 GetOrLoad
+type Loader struct {
+	cache       Cache
+	negativeTTL time.Duration
+	group       singleflight
+}
+
+func NewLoader(c Cache, opts LoaderOptions) *Loader {
+	return &Loader{
+		cache:       c,
+		negativeTTL: opts.NegativeTTL,
+	}
+}
+
+// Cache 返回底层的 Cache
+func (l *Loader) Cache() Cache {
+	return l.cache
+}
+
+// GetOrLoad 先读缓存，未命中时调用 fn 加载并以 ttl 写入缓存
+// 数据不存在时 Exists 为 false，fn 返回的其他错误通过 Error 返回
+func (l *Loader) GetOrLoad(key string, ttl time.Duration, fn LoaderFunc) *Cmd {
+	if cmd, ok := l.get(key); ok {
+		return cmd
+	}
+	cmd, _ := l.group.do(key, func() *Cmd {
+		// 等待期间其他调用方可能已经写入
+		if cmd, ok := l.get(key); ok {
+			return cmd
+		}
+		return l.load(key, ttl, fn)
+	})
+	// 共享的结果复制一份，避免调用方之间互相影响
+	result := *cmd
+	return &result
+}
+
+// get 命中时返回 true，读取出错时按未命中处理，继续回源
+func (l *Loader) get(key string) (*Cmd, bool) {
+	cmd := l.cache.Get(key)
+	if cmd.Error() != nil || !cmd.Exists() {
+		return nil, false
+	}
+	if isNegativeValue(cmd.Val()) {
+		return &Cmd{baseCmd: baseCmd{ttl: cmd.TTL()}}, true
+	}
+	return cmd, true
+}
+
+func (l *Loader) load(key string, ttl time.Duration, fn LoaderFunc) *Cmd {
+	value, err := fn(key)
+	if err == ErrNil {
+		if l.negativeTTL > 0 {
+			l.cache.Set(key, negativeValue, l.negativeTTL)
+		}
+		return &Cmd{}
+	}
+	if err != nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+	// 写入失败不影响本次返回，下次读取会重新加载
+	l.cache.Set(key, value, ttl)
+	if ttl <= 0 {
+		ttl = -1
+	}
+	return &Cmd{baseCmd: baseCmd{exists: true, ttl: ttl}, value: value}
+}
+
+func isNegativeValue(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return v == negativeValue
+	case []byte:
+		return string(v) == negativeValue
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoader_GetOrLoad(t *testing.T) {
+	loader := NewLoader(NewMemCache(NewDefaultOptions()), LoaderOptions{})
+
+	var calls int32
+	start := make(chan struct{})
+	fn := func(key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-start
+		return key + "-value", nil
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd := loader.GetOrLoad("k", time.Minute, fn)
+			if cmd.Error() != nil || !cmd.Exists() || cmd.ValString() != "k-value" {
+				t.Error(cmd.Error(), cmd.Exists(), cmd.ValString())
+			}
+		}()
+	}
+	// 等待所有调用方进入等待后再返回
+	time.Sleep(50 * time.Millisecond)
+	close(start)
+	wg.Wait()
+	if calls != 1 {
+		t.Fatal("loader calls", calls)
+	}
+
+	// 已经写入缓存，不再回源
+	if cmd := loader.GetOrLoad("k", time.Minute, fn); cmd.ValString() != "k-value" || calls != 1 {
+		t.Fatal(cmd.ValString(), calls)
+	}
+	if cmd := loader.Cache().Get("k"); cmd.ValString() != "k-value" {
+		t.Fatal(cmd.ValString())
+	}
+}
+
+func TestLoader_Error(t *testing.T) {
+	memCache := NewMemCache(NewDefaultOptions())
+	loader := NewLoader(memCache, LoaderOptions{})
+
+	errDB := errors.New("db error")
+	calls := 0
+	fn := func(key string) (interface{}, error) {
+		calls++
+		return nil, errDB
+	}
+	for i := 0; i < 2; i++ {
+		if cmd := loader.GetOrLoad("k", time.Minute, fn); cmd.Error() != errDB {
+			t.Fatal(cmd.Error())
+		}
+	}
+	// 错误不缓存
+	if calls != 2 || memCache.Get("k").Exists() {
+		t.Fatal(calls)
+	}
+
+	// 未开启 NegativeTTL 时，不存在的结果也不缓存
+	notFound := func(key string) (interface{}, error) {
+		calls++
+		return nil, ErrNil
+	}
+	if cmd := loader.GetOrLoad("k", time.Minute, notFound); cmd.Error() != nil || cmd.Exists() {
+		t.Fatal(cmd.Error(), cmd.Exists())
+	}
+	if calls != 3 || memCache.Get("k").Exists() {
+		t.Fatal(calls)
+	}
+}
+
+func TestLoader_Negative(t *testing.T) {
+	rc, s := newTestRedisCache(t)
+	loader := NewLoader(rc, LoaderOptions{NegativeTTL: time.Minute})
+
+	calls := 0
+	fn := func(key string) (interface{}, error) {
+		calls++
+		return nil, ErrNil
+	}
+	for i := 0; i < 3; i++ {
+		if cmd := loader.GetOrLoad("k", time.Hour, fn); cmd.Error() != nil || cmd.Exists() {
+			t.Fatal(cmd.Error(), cmd.Exists())
+		}
+	}
+	if calls != 1 {
+		t.Fatal("loader calls", calls)
+	}
+	if ttl := s.TTL("k"); ttl != time.Minute {
+		t.Fatal(ttl)
+	}
+
+	// 过期后重新加载
+	s.FastForward(time.Minute)
+	found := func(key string) (interface{}, error) {
+		calls++
+		return 1, nil
+	}
+	if cmd := loader.GetOrLoad("k", time.Hour, found); cmd.Error() != nil || !cmd.Exists() {
+		t.Fatal(cmd.Error(), cmd.Exists())
+	} else if n, _ := cmd.Int64(); n != 1 {
+		t.Fatal(n)
+	}
+	if cmd := loader.GetOrLoad("k", time.Hour, found); calls != 2 || cmd.ValString() != "1" {
+		t.Fatal(calls, cmd.ValString())
+	}
+}
@@ -1,11 +1,14 @@
 package cache
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -28,33 +31,98 @@ var (
 
 type Options struct {
 	// 缓存容量， 默认 -1  不限制  byte
-	Size int32
+	Size int64
+	// 最多保存的 key 数量，默认 -1 不限制
+	MaxEntries int64
+	// 估算 key 和 value 占用的字节数，默认 DefaultSizer
+	Sizer Sizer
 	// 自动清除, 当ttl key存在不多时，可以关闭，默认关闭
-	AutoClean bool
+	// 每隔 CleanInterval 抽样清理过期的 key，CleanInterval > 0 时同样开启，为 0 时使用 DefaultCleanInterval
+	AutoClean     bool
+	CleanInterval time.Duration
+	// 开启后使用时间轮在 key 到期时删除，适合大量短过期时间的 key，开启后不需要 AutoClean
+	// ExpireWheelTick 时间轮的精度，默认 DefaultExpireWheelTick
+	ExpireWheel     bool
+	ExpireWheelTick time.Duration
 	// 保存文件位置, 默认 不设置，不能 Save
 	Filename string
+	// 开启后每次修改追加写入日志 Filename + ".aof"，需要设置 Filename，默认关闭
+	AppendOnly bool
+	// 日志同步到磁盘的策略，默认 FsyncEverySec
+	AppendFsync FsyncPolicy
+	// 日志超过该大小时在后台压缩为快照，默认 DefaultAppendCompactSize
+	AppendCompactSize int64
+	// 有修改时每隔 SaveInterval 在后台保存一次快照，需要设置 Filename，默认 0 不保存
+	SaveInterval time.Duration
+	// 满足任意一个 SavePoint 时在后台保存快照，与 SaveInterval 可以同时使用
+	SavePoints []SavePoint
+	// 保存快照和日志时值的编码方式，默认 JSONCodec，只影响 MemCache 的持久化，不影响读写的值和 RedisCache
+	Codec Codec
+	// 超出容量时的淘汰策略，默认 EvictionNone 写入失败
+	Eviction EvictionPolicy
+	// 分片数量，默认 1 不分片。多核并发读写较多时，可以设置为核数的倍数
+	// 不淘汰时容量按所有分片的总量计算；开启淘汰策略时容量和 key 数量平均分配到每个分片，
+	// 每个分片超出自己的容量时只淘汰分片内的 key，分片数量大于 Size 或 MaxEntries 时减少到该数量
+	Shards int
+
+	// key 被删除时的回调，在释放锁之后执行
+	// OnExpire 过期，OnEvict 容量不足被淘汰，OnDelete 主动删除或 FlushAll
+	OnExpire RemoveCallback
+	OnEvict  RemoveCallback
+	OnDelete RemoveCallback
 }
 
 func NewDefaultOptions() Options {
 	return Options{
-		Size:      -1,
-		AutoClean: false,
-		Filename:  "",
+		Size:       -1,
+		MaxEntries: -1,
+		AutoClean:  false,
+		Filename:   "",
+		Eviction:   EvictionNone,
+		Shards:     1,
 	}
 }
 
 type MemCache struct {
-	rwMutex sync.RWMutex
-	store   map[string]WrapValue
+	shards []*memShard
 
 	// 缓存容量， -1 - 不限制
-	size        int32
-	currentSize int32
+	size        int64
+	currentSize int64
+	// key 数量限制， -1 - 不限制
+	maxEntries int64
+	entries    int64
+	sizer      Sizer
 
 	// 保存文件位置, 不设置，默认当前执行路径
 	filename string
-	// 自动清除
-	autoClean bool
+	// 下一次清理过期 key 的分片，只在清理的 goroutine 中使用
+	cleanNext int
+	// 过期时间轮，nil 表示未开启
+	wheel *timingWheel
+	// 追加日志，nil 表示未开启
+	aof *appendLog
+	// Save 和日志压缩不能同时执行
+	saveMutex sync.Mutex
+	codec     Codec
+
+	// 上次保存之后的修改次数
+	changes  int64
+	saveInfo saveInfo
+	// 关闭后台任务
+	closing   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	// 命中、写入、删除等次数
+	stats memStats
+
+	// BLPop 等待写入
+	listWaiters *listWaiters
+
+	onExpire RemoveCallback
+	onEvict  RemoveCallback
+	onDelete RemoveCallback
 }
 
 func NewMemCache(opts ...Options) *MemCache {
@@ -62,40 +130,123 @@ func NewMemCache(opts ...Options) *MemCache {
 	if len(opts) > 0 {
 		opt = opts[0]
 	}
+	if opt.Shards <= 0 {
+		opt.Shards = 1
+	}
+	// 每个分片至少分到 1，否则总量会超出限制
+	if opt.Eviction != EvictionNone {
+		for _, limit := range []int64{opt.Size, opt.MaxEntries} {
+			if limit > 0 && int64(opt.Shards) > limit {
+				opt.Shards = int(limit)
+			}
+		}
+	}
+	if opt.Sizer == nil {
+		opt.Sizer = DefaultSizer
+	}
+	if opt.Codec == nil {
+		opt.Codec = JSONCodec
+	}
 	mem := &MemCache{
-		store:       make(map[string]WrapValue),
+		shards:      make([]*memShard, opt.Shards),
 		size:        opt.Size,
 		currentSize: 0,
+		maxEntries:  opt.MaxEntries,
+		sizer:       opt.Sizer,
 		filename:    opt.Filename,
-		autoClean:   opt.AutoClean,
+		codec:       opt.Codec,
+		listWaiters: newListWaiters(),
+		onExpire:    opt.OnExpire,
+		onEvict:     opt.OnEvict,
+		onDelete:    opt.OnDelete,
+		closing:     make(chan struct{}),
 	}
-
-	if mem.autoClean {
-		go mem.autoExpireClean(5 * time.Minute)
+	for i := range mem.shards {
+		mem.shards[i] = newMemShard(opt.Eviction)
+	}
+	// 淘汰时不能锁住其他分片，所以每个分片单独限制容量
+	if opt.Eviction != EvictionNone && opt.Shards > 1 {
+		for i, sh := range mem.shards {
+			sh.size = splitLimit(opt.Size, opt.Shards, i)
+			sh.maxEntries = splitLimit(opt.MaxEntries, opt.Shards, i)
+		}
+		mem.size, mem.maxEntries = -1, -1
+	}
+	if opt.ExpireWheel {
+		mem.wheel = newTimingWheel(opt.ExpireWheelTick)
 	}
 
-	if err := mem.load(); err != nil {
+	gen, err := mem.load()
+	if err != nil {
 		log.Printf("WARNING: load file cache error %s \n", err.Error())
 	}
+	if opt.AppendOnly && mem.filename != "" {
+		if err := mem.openAppendLog(opt.AppendFsync, opt.AppendCompactSize, gen); err != nil {
+			log.Printf("WARNING: open append log error %s \n", err.Error())
+		}
+	}
+	// 加载的数据已经在文件中，也不计入统计
+	atomic.StoreInt64(&mem.changes, 0)
+	mem.stats.reset()
+	mem.startAutoSave(opt.SaveInterval, opt.SavePoints)
+
+	if opt.AutoClean && opt.CleanInterval <= 0 {
+		opt.CleanInterval = DefaultCleanInterval
+	}
+	if mem.wheel != nil {
+		mem.startExpireWheel()
+	} else {
+		mem.startAutoClean(opt.CleanInterval)
+	}
 
 	return mem
 }
 
+// ValueKind 值的数据类型
+type ValueKind string
+
+const (
+	KindString ValueKind = ""
+	KindHash   ValueKind = "hash"
+	KindList   ValueKind = "list"
+	KindZSet   ValueKind = "zset"
+)
+
 type WrapValue struct {
 	Value       interface{} `json:"v"`
 	ExpiredTime time.Time   `json:"e"`
-	Size        int32       `json:"s"`
+	Size        int64       `json:"s"`
+	Kind        ValueKind   `json:"k,omitempty"`
+	// Tags SetWithTags 写入的标签
+	Tags []string `json:"t,omitempty"`
 }
 
+// SetExpiredTime 与 redis 一致，t <= 0 时不过期，ExpiredTime 为零值
 func (val *WrapValue) SetExpiredTime(t time.Duration) {
-	if t <= -1 {
-		val.ExpiredTime = time.Now().AddDate(100, 0, 0)
+	if t <= 0 {
+		val.ExpiredTime = time.Time{}
 		return
 	}
 	val.ExpiredTime = time.Now().Add(t)
 }
 
+// Persistent 是否没有过期时间
+func (val *WrapValue) Persistent() bool {
+	return val.ExpiredTime.IsZero()
+}
+
+// restoreLegacyExpire 版本 1 的文件中没有过期时间的 key 保存为 100 年后，加载时还原为零值
+func restoreLegacyExpire(val *WrapValue) {
+	if val.ExpiredTime.After(time.Now().AddDate(50, 0, 0)) {
+		val.ExpiredTime = time.Time{}
+	}
+}
+
+// TTL 剩余的过期时间，与 redis 一致，没有过期时间时为 -1
 func (val *WrapValue) TTL() time.Duration {
+	if val.Persistent() {
+		return -1
+	}
 	expire := val.ExpiredTime.Sub(time.Now())
 	// 存在这种可能
 	if expire < 0 {
@@ -105,210 +256,754 @@ func (val *WrapValue) TTL() time.Duration {
 }
 
 func (val *WrapValue) Expired() bool {
-	return val.ExpiredTime.Before(time.Now())
+	return !val.Persistent() && val.ExpiredTime.Before(time.Now())
+}
+
+func (mem *MemCache) shard(key string) *memShard {
+	return mem.shards[shardIndex(key, len(mem.shards))]
+}
+
+// lockAll 按顺序锁住所有分片，跨分片的操作保持原子性
+func (mem *MemCache) lockAll() {
+	for _, sh := range mem.shards {
+		sh.rwMutex.Lock()
+	}
+}
+
+func (mem *MemCache) unlockAll() {
+	mem.unlockShards(mem.shards)
+}
+
+func (mem *MemCache) rLockAll() {
+	for _, sh := range mem.shards {
+		sh.rwMutex.RLock()
+	}
+}
+
+func (mem *MemCache) rUnlockAll() {
+	for _, sh := range mem.shards {
+		sh.rwMutex.RUnlock()
+	}
+}
+
+// keyShards 返回 keys 所在的分片，按分片顺序排列，用于批量加锁
+func (mem *MemCache) keyShards(keys []string) []*memShard {
+	if len(mem.shards) == 1 {
+		return mem.shards
+	}
+	used := make([]bool, len(mem.shards))
+	for _, key := range keys {
+		used[shardIndex(key, len(mem.shards))] = true
+	}
+	shards := make([]*memShard, 0, len(keys))
+	for i, ok := range used {
+		if ok {
+			shards = append(shards, mem.shards[i])
+		}
+	}
+	return shards
 }
 
 func (mem *MemCache) Get(key string) *Cmd {
-	mem.rwMutex.RLock()
-	val, ok := mem.store[key]
-	mem.rwMutex.RUnlock()
+	sh := mem.shard(key)
+	sh.rwMutex.RLock()
+	val, ok := sh.store[key]
+	sh.rwMutex.RUnlock()
 	if ok {
 		// 如果过期了，就删除了
 		if val.Expired() {
 			mem.delete(key, true)
+			atomic.AddInt64(&mem.stats.misses, 1)
 			return &Cmd{baseCmd: baseCmd{exists: false}, value: nil}
 		}
+		if val.Kind != KindString {
+			return &Cmd{baseCmd: baseCmd{err: ErrWrongType}}
+		}
+		if sh.evictor != nil {
+			sh.evictor.access(key)
+		}
+		atomic.AddInt64(&mem.stats.hits, 1)
 
 		return &Cmd{baseCmd: baseCmd{exists: ok, ttl: val.TTL()}, value: val.Value}
 	}
+	atomic.AddInt64(&mem.stats.misses, 1)
 	return &Cmd{}
 }
 
 func (mem *MemCache) Set(key string, value interface{}, ttl time.Duration) *StatusCmd {
-	val := WrapValue{
-		Value: value,
-	}
-	if mem.size > 0 {
-		val.Size = int32(len(key) + len(fmt.Sprint(value)))
-	}
+	val := mem.newWrapValue(key, value)
 	val.SetExpiredTime(ttl)
 
-	if err := mem.set(key, val); err != nil {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	keepTagsLocked(sh, key, &val)
+	err := mem.setLocked(sh, key, val)
+	mem.unlock(sh)
+	if err != nil {
 		return &StatusCmd{baseCmd: baseCmd{exists: false, err: err}}
 	}
 
 	return &StatusCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: StatusOK}
 }
 
+func (mem *MemCache) newWrapValue(key string, value interface{}) WrapValue {
+	return WrapValue{
+		Value: value,
+		Size:  mem.sizeOf(key, value),
+	}
+}
+
 func (mem *MemCache) set(key string, val WrapValue) error {
-	mem.rwMutex.Lock()
-	addSize := int32(0)
-	oldVal, ok := mem.store[key]
-	if !ok {
-		if err := mem.isOverSize(val.Size); err != nil {
-			return err
-		}
-		addSize = val.Size
-	} else {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	defer mem.unlock(sh)
+	return mem.setLocked(sh, key, val)
+}
+
+// setLocked 写入并记录日志，调用方持有分片写锁
+func (mem *MemCache) setLocked(sh *memShard, key string, val WrapValue) error {
+	if err := mem.storeLocked(sh, key, val); err != nil {
+		return err
+	}
+	mem.logSetLocked(key, val)
+	atomic.AddInt64(&mem.stats.sets, 1)
+	return nil
+}
+
+// storeLocked 写入 key 并占用容量，不记录日志，调用方持有分片写锁
+func (mem *MemCache) storeLocked(sh *memShard, key string, val WrapValue) error {
+	addSize, addEntries := val.Size, int64(1)
+	oldVal, ok := sh.store[key]
+	if ok {
 		// 存在则计算容量
-		subSize := val.Size - oldVal.Size
-		if err := mem.isOverSize(subSize); err != nil {
+		addSize, addEntries = val.Size-oldVal.Size, 0
+	}
+	if !mem.reserve(sh, addSize, addEntries) {
+		// 单个 key 超过总容量时，淘汰也放不下
+		if sh.evictor == nil || !mem.fits(sh, val.Size) {
+			return ErrKeysOverCapacity
+		}
+		// 先淘汰其他的 key，失败时保留旧值
+		if ok {
+			sh.evictor.remove(key)
+		}
+		if err := mem.evictLocked(sh, addSize, addEntries); err != nil {
+			if ok {
+				sh.evictor.add(key, oldVal)
+			}
 			return err
 		}
-		addSize = subSize
 	}
-	mem.store[key] = val
+	if ok {
+		sh.untagLocked(key, oldVal.Tags)
+		// 覆盖已过期的值也需要通知过期
+		if oldVal.Expired() {
+			mem.recordLocked(sh, key, oldVal, RemoveExpired)
+		}
+	} else {
+		sh.indexLocked(key)
+	}
+	sh.store[key] = val
+	mem.trackExpireLocked(sh, key, val)
+	sh.tagLocked(key, val.Tags)
+	if sh.evictor != nil {
+		sh.evictor.add(key, val)
+	}
+
+	return nil
+}
+
+// growLocked 已存在的 key 需要再占用 size 容量，必要时淘汰分片内其他的 key，调用方持有分片写锁
+func (mem *MemCache) growLocked(sh *memShard, key string, size int64) error {
+	if mem.reserve(sh, size, 0) {
+		return nil
+	}
+	if sh.evictor == nil {
+		return ErrKeysOverCapacity
+	}
+	// 不能淘汰正在写入的 key
+	sh.evictor.remove(key)
+	err := mem.evictLocked(sh, size, 0)
+	sh.evictor.add(key, sh.store[key])
+	return err
+}
 
-	atomic.AddInt32(&mem.currentSize, addSize)
+// sizeOf 估算占用容量
+func (mem *MemCache) sizeOf(key string, value interface{}) int64 {
+	return mem.sizer.Size(key, value)
+}
 
-	mem.rwMutex.Unlock()
+// reserve 占用容量和 key 数量，超出总量或分片的限制返回 false
+func (mem *MemCache) reserve(sh *memShard, size, entries int64) bool {
+	if !reserveInt64(&mem.currentSize, mem.size, size) {
+		return false
+	}
+	if !reserveInt64(&mem.entries, mem.maxEntries, entries) {
+		atomic.AddInt64(&mem.currentSize, -size)
+		return false
+	}
+	if !reserveInt64(&sh.currentSize, sh.size, size) {
+		atomic.AddInt64(&mem.currentSize, -size)
+		atomic.AddInt64(&mem.entries, -entries)
+		return false
+	}
+	if !reserveInt64(&sh.entries, sh.maxEntries, entries) {
+		atomic.AddInt64(&mem.currentSize, -size)
+		atomic.AddInt64(&mem.entries, -entries)
+		atomic.AddInt64(&sh.currentSize, -size)
+		return false
+	}
+	return true
+}
+
+// fits 单个 key 是否能放入总量和分片的容量
+func (mem *MemCache) fits(sh *memShard, size int64) bool {
+	return (mem.size <= 0 || size <= mem.size) && (sh.size <= 0 || size <= sh.size)
+}
 
+// reserveInt64 多个分片会同时写入，使用 CAS 保证总量不超出 limit，limit <= 0 不限制
+func reserveInt64(current *int64, limit, n int64) bool {
+	for {
+		old := atomic.LoadInt64(current)
+		if limit > 0 && n > 0 && old+n > limit {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(current, old, old+n) {
+			return true
+		}
+	}
+}
+
+// evictLocked 按淘汰策略删除分片内的 key，直到可以占用 size 容量和 entries 个 key，调用方持有分片写锁
+func (mem *MemCache) evictLocked(sh *memShard, size, entries int64) error {
+	for !mem.reserve(sh, size, entries) {
+		key, ok := sh.evictor.victim()
+		if !ok {
+			return ErrKeysOverCapacity
+		}
+		mem.removeLocked(sh, key, sh.store[key], RemoveEvicted)
+	}
 	return nil
 }
 
+// removeLocked 删除 key 并释放容量，记录回调事件，调用方持有分片写锁
+func (mem *MemCache) removeLocked(sh *memShard, key string, val WrapValue, reason RemoveReason) {
+	mem.recordLocked(sh, key, val, reason)
+	mem.logDelLocked(key)
+	delete(sh.store, key)
+	sh.unindexLocked(key)
+	mem.untrackExpireLocked(sh, key)
+	sh.untagLocked(key, val.Tags)
+	mem.reserve(sh, -val.Size, -1)
+	if sh.evictor != nil {
+		sh.evictor.remove(key)
+	}
+}
+
+// EvictedCount 因容量不足被淘汰的 key 数量
+func (mem *MemCache) EvictedCount() int64 {
+	return atomic.LoadInt64(&mem.stats.evictions)
+}
+
+// update 在分片写锁内读取并修改 key 的值
+// key 不存在或已过期时 exists 为 false，新建的 key 使用 ttl，否则保留原过期时间和标签
+func (mem *MemCache) update(key string, ttl time.Duration, fn func(old interface{}, exists bool) (interface{}, error)) (WrapValue, error) {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	defer mem.unlock(sh)
+	old, ok := sh.store[key]
+	if ok && old.Expired() {
+		ok = false
+		old = WrapValue{}
+	}
+	if ok && old.Kind != KindString {
+		return WrapValue{}, ErrWrongType
+	}
+	value, err := fn(old.Value, ok)
+	if err != nil {
+		return WrapValue{}, err
+	}
+	val := mem.newWrapValue(key, value)
+	if ok {
+		val.ExpiredTime = old.ExpiredTime
+		val.Tags = old.Tags
+	} else {
+		val.SetExpiredTime(ttl)
+	}
+	return val, mem.setLocked(sh, key, val)
+}
+
+func (mem *MemCache) IncrBy(key string, value int64, ttl time.Duration) *IntCmd {
+	var result int64
+	val, err := mem.update(key, ttl, func(old interface{}, exists bool) (interface{}, error) {
+		n := int64(0)
+		if exists {
+			i, err := toInt64(old)
+			if err != nil {
+				return nil, ErrNotInteger
+			}
+			n = i
+		}
+		if (value > 0 && n > math.MaxInt64-value) || (value < 0 && n < math.MinInt64-value) {
+			return nil, ErrNotInteger
+		}
+		result = n + value
+		return result, nil
+	})
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: result}
+}
+
+func (mem *MemCache) DecrBy(key string, value int64, ttl time.Duration) *IntCmd {
+	if value == math.MinInt64 {
+		return &IntCmd{baseCmd: baseCmd{err: ErrNotInteger}}
+	}
+	return mem.IncrBy(key, -value, ttl)
+}
+
+func (mem *MemCache) IncrByFloat(key string, value float64, ttl time.Duration) *FloatCmd {
+	var result float64
+	val, err := mem.update(key, ttl, func(old interface{}, exists bool) (interface{}, error) {
+		f := float64(0)
+		if exists {
+			n, err := toFloat64(old)
+			if err != nil {
+				return nil, ErrNotFloat
+			}
+			f = n
+		}
+		result = f + value
+		if math.IsNaN(result) || math.IsInf(result, 0) {
+			return nil, ErrNotFloat
+		}
+		return result, nil
+	})
+	if err != nil {
+		return &FloatCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &FloatCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: result}
+}
+
+// setIf 在分片写锁内判断条件，满足时写入，key 已过期时 exists 为 false
+func (mem *MemCache) setIf(key string, value interface{}, ttl time.Duration, cond func(old WrapValue, exists bool) (bool, error)) (WrapValue, bool, error) {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	defer mem.unlock(sh)
+	old, ok := sh.store[key]
+	if ok && old.Expired() {
+		ok = false
+		old = WrapValue{}
+	}
+	if yes, err := cond(old, ok); err != nil || !yes {
+		return old, false, err
+	}
+	val := mem.newWrapValue(key, value)
+	val.SetExpiredTime(ttl)
+	val.Tags = old.Tags
+	if err := mem.setLocked(sh, key, val); err != nil {
+		return old, false, err
+	}
+	return val, true, nil
+}
+
+func (mem *MemCache) SetNX(key string, value interface{}, ttl time.Duration) *BoolCmd {
+	return mem.setIfCmd(key, value, ttl, func(_ WrapValue, exists bool) (bool, error) {
+		return !exists, nil
+	})
+}
+
+func (mem *MemCache) SetXX(key string, value interface{}, ttl time.Duration) *BoolCmd {
+	return mem.setIfCmd(key, value, ttl, func(_ WrapValue, exists bool) (bool, error) {
+		return exists, nil
+	})
+}
+
+func (mem *MemCache) CompareAndSwap(key string, old, new interface{}, ttl time.Duration) *BoolCmd {
+	return mem.setIfCmd(key, new, ttl, func(val WrapValue, exists bool) (bool, error) {
+		if exists && val.Kind != KindString {
+			return false, ErrWrongType
+		}
+		return exists && valueEqual(val.Value, old), nil
+	})
+}
+
+func (mem *MemCache) setIfCmd(key string, value interface{}, ttl time.Duration, cond func(old WrapValue, exists bool) (bool, error)) *BoolCmd {
+	val, ok, err := mem.setIf(key, value, ttl, cond)
+	if err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	if !ok {
+		return &BoolCmd{}
+	}
+	return &BoolCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: true}
+}
+
+func (mem *MemCache) GetSet(key string, value interface{}, ttl time.Duration) *Cmd {
+	var (
+		oldVal WrapValue
+		exists bool
+	)
+	_, _, err := mem.setIf(key, value, ttl, func(old WrapValue, ok bool) (bool, error) {
+		if ok && old.Kind != KindString {
+			return false, ErrWrongType
+		}
+		oldVal, exists = old, ok
+		return true, nil
+	})
+	if err != nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+	if !exists {
+		return &Cmd{}
+	}
+	return &Cmd{baseCmd: baseCmd{exists: true, ttl: oldVal.TTL()}, value: oldVal.Value}
+}
+
+// MGet 所有 key 只加一次锁
+func (mem *MemCache) MGet(keys ...string) *MultiCmd {
+	cmds := make([]*Cmd, len(keys))
+	expired := make([]string, 0)
+	hits := int64(0)
+	shards := mem.keyShards(keys)
+	for _, sh := range shards {
+		sh.rwMutex.RLock()
+	}
+	for i, key := range keys {
+		sh := mem.shard(key)
+		val, ok := sh.store[key]
+		// 与 redis 一致，其他数据类型返回不存在
+		if !ok || val.Kind != KindString {
+			cmds[i] = &Cmd{}
+			continue
+		}
+		if val.Expired() {
+			expired = append(expired, key)
+			cmds[i] = &Cmd{}
+			continue
+		}
+		if sh.evictor != nil {
+			sh.evictor.access(key)
+		}
+		cmds[i] = &Cmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: val.Value}
+		hits++
+	}
+	for _, sh := range shards {
+		sh.rwMutex.RUnlock()
+	}
+	atomic.AddInt64(&mem.stats.hits, hits)
+	atomic.AddInt64(&mem.stats.misses, int64(len(keys))-hits)
+
+	for _, key := range expired {
+		mem.delete(key, true)
+	}
+	return &MultiCmd{value: cmds}
+}
+
+// MSet 所有 key 只加一次锁，超出容量时返回错误，之前的 key 已经写入
+func (mem *MemCache) MSet(values map[string]interface{}, ttl time.Duration) *StatusCmd {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	shards := mem.keyShards(keys)
+	for _, sh := range shards {
+		sh.rwMutex.Lock()
+	}
+	defer mem.unlockShards(shards)
+
+	for _, key := range keys {
+		sh := mem.shard(key)
+		val := mem.newWrapValue(key, values[key])
+		val.SetExpiredTime(ttl)
+		keepTagsLocked(sh, key, &val)
+		if err := mem.setLocked(sh, key, val); err != nil {
+			return &StatusCmd{baseCmd: baseCmd{err: err}}
+		}
+	}
+	return &StatusCmd{value: StatusOK}
+}
+
+// MDelete 所有 key 只加一次锁
+func (mem *MemCache) MDelete(keys ...string) *IntCmd {
+	shards := mem.keyShards(keys)
+	for _, sh := range shards {
+		sh.rwMutex.Lock()
+	}
+	n := int64(0)
+	for _, key := range keys {
+		sh := mem.shard(key)
+		val, ok := sh.store[key]
+		if !ok {
+			continue
+		}
+		if !val.Expired() {
+			n++
+		}
+		mem.removeLocked(sh, key, val, RemoveDeleted)
+	}
+	mem.unlockShards(shards)
+	return &IntCmd{value: n}
+}
+
 func (mem *MemCache) Delete(key string) *StatusCmd {
 	mem.delete(key, false)
 	return &StatusCmd{value: StatusOK}
 }
 
 func (mem *MemCache) delete(key string, isExpired bool) {
-	mem.rwMutex.Lock()
-	val, ok := mem.store[key]
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	val, ok := sh.store[key]
 	if ok {
 		if (isExpired && val.Expired()) || !isExpired {
 			// 过期删除，并且确实过期，才删除
 			// 非过期删除，则直接删除
-			delete(mem.store, key)
-			atomic.AddInt32(&mem.currentSize, -val.Size)
+			mem.removeLocked(sh, key, val, RemoveDeleted)
 		}
 	}
-	mem.rwMutex.Unlock()
+	mem.unlock(sh)
 }
 
 func (mem *MemCache) Keys(prefix string) *SliceStringCmd {
-	mem.rwMutex.RLock()
-	defer mem.rwMutex.RUnlock()
+	return mem.KeysContext(context.Background(), prefix)
+}
+
+// KeysContext 遍历过程中 ctx 结束时返回 ctx.Err()
+func (mem *MemCache) KeysContext(ctx context.Context, prefix string) *SliceStringCmd {
 	keys := make([]string, 0)
-	for key := range mem.store {
-		if strings.HasPrefix(key, prefix) {
-			keys = append(keys, key)
+	for _, sh := range mem.shards {
+		if err := mem.scanShard(ctx, sh, func(key string, _ WrapValue) {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}); err != nil {
+			return &SliceStringCmd{baseCmd: baseCmd{err: err}}
 		}
 	}
 	return &SliceStringCmd{value: keys}
 }
 
-// FlushAll 清空所有数据
-func (mem *MemCache) FlushAll() *StatusCmd {
-	mem.rwMutex.Lock()
-	defer mem.rwMutex.Unlock()
-	mem.store = make(map[string]WrapValue)
-	mem.currentSize = 0
-	return &StatusCmd{value: StatusOK}
-}
+// scanCheckEvery 遍历分片时，每隔多少个 key 检查一次 ctx
+const scanCheckEvery = 1024
 
-// Close 开启写入磁盘，则写入文件
-func (mem *MemCache) Close() error {
-	if mem.filename != "" {
-		saveCmd := mem.Save()
-		if saveCmd.Error() != nil {
-			return saveCmd.Error()
+// scanShard 在分片读锁内遍历，ctx 结束时停止
+func (mem *MemCache) scanShard(ctx context.Context, sh *memShard, fn func(key string, val WrapValue)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	sh.rwMutex.RLock()
+	defer sh.rwMutex.RUnlock()
+	i := 0
+	for key, val := range sh.store {
+		if i++; i%scanCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 		}
+		fn(key, val)
 	}
 	return nil
 }
 
-func (mem *MemCache) isOverSize(size int32) error {
-	if mem.size <= 0 {
-		return nil
-	}
-
-	if atomic.LoadInt32(&mem.currentSize)+size > mem.size {
-		return ErrKeysOverCapacity
-	}
-	return nil
+// FlushAll 清空所有数据
+func (mem *MemCache) FlushAll() *StatusCmd {
+	mem.lockAll()
+	defer mem.unlockAll()
+	mem.flushLocked(RemoveFlushed)
+	mem.logFlushLocked()
+	return &StatusCmd{value: StatusOK}
 }
 
-func (mem *MemCache) scanExpiredKeyAndDel() {
-	mem.rwMutex.RLock()
-	dels := make([]struct {
-		Key string
-		Val WrapValue
-	}, 0)
-	for k, v := range mem.store {
-		if v.Expired() {
-			dels = append(dels, struct {
-				Key string
-				Val WrapValue
-			}{Key: k, Val: v})
+// flushLocked 清空所有分片，调用方持有所有分片的写锁
+func (mem *MemCache) flushLocked(reason RemoveReason) {
+	for _, sh := range mem.shards {
+		for key, val := range sh.store {
+			mem.recordLocked(sh, key, val, reason)
+		}
+		sh.store = make(map[string]WrapValue)
+		sh.tags = make(map[string]map[string]struct{})
+		sh.buckets = make([]map[string]struct{}, scanBuckets)
+		sh.volatile = make(map[string]struct{})
+		atomic.StoreInt64(&sh.currentSize, 0)
+		atomic.StoreInt64(&sh.entries, 0)
+		if sh.evictor != nil {
+			sh.evictor.reset()
 		}
 	}
-	mem.rwMutex.RUnlock()
-
-	for _, del := range dels {
-		mem.delete(del.Key, true)
+	if mem.wheel != nil {
+		mem.wheel.reset()
 	}
+	atomic.StoreInt64(&mem.currentSize, 0)
+	atomic.StoreInt64(&mem.entries, 0)
 }
 
-// autoExpireClean 自动在一定时间内清理过期key
-// 当设置了大量的 expire key 且通常只读取一次的情况下再建议使用。
-// interval 建议设置大一点，否则可能影响读性能，建议设置 5 minute
-// go AutoCleanExpireKey(5 * time.Minute)
-func (mem *MemCache) autoExpireClean(interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	for {
-		select {
-		case <-ticker.C:
-			mem.scanExpiredKeyAndDel()
+// Close 停止后台保存和过期清理，开启写入磁盘，则写入文件，开启追加日志时关闭日志
+func (mem *MemCache) Close() error {
+	mem.closeOnce.Do(func() {
+		close(mem.closing)
+	})
+	mem.wg.Wait()
+	if mem.filename != "" {
+		saveCmd := mem.Save()
+		if saveCmd.Error() != nil {
+			return saveCmd.Error()
 		}
 	}
+	if mem.aof != nil {
+		return mem.aof.close()
+	}
+	return nil
 }
 
 func (mem *MemCache) Save() *StatusCmd {
+	return mem.SaveContext(context.Background())
+}
+
+// SaveContext 写入文件前 ctx 结束时不再写入
+// 开启追加日志时同时压缩日志，快照写入成功后删除之前的日志
+func (mem *MemCache) SaveContext(ctx context.Context) *StatusCmd {
 	if mem.filename == "" {
 		return &StatusCmd{baseCmd: baseCmd{err: ErrFilenameEmpty}}
 	}
+	mem.saveMutex.Lock()
+	defer mem.saveMutex.Unlock()
 
+	start := time.Now()
+	changes, err := mem.saveLocked(ctx)
+	mem.recordSave(start, changes, err)
+	return &StatusCmd{baseCmd: baseCmd{err: err}}
+}
+
+// saveLocked 返回快照包含的修改次数，调用方持有 saveMutex
+func (mem *MemCache) saveLocked(ctx context.Context) (int64, error) {
 	disk, err := NewDisk(mem.filename)
 	if err != nil {
-		return &StatusCmd{baseCmd: baseCmd{err: err}}
+		return 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	mem.rLockAll()
+	// 修改都在分片写锁内计数，持有所有读锁时计数和快照一致
+	changes := atomic.LoadInt64(&mem.changes)
+	// 快照记录压缩后新日志的代数
+	gen := int64(0)
+	if mem.aof != nil {
+		gen = mem.aof.gen + 1
+	}
+	byt, err := mem.snapshotLocked(gen)
+	if err == nil && mem.aof != nil {
+		// 快照之后的修改写入新的日志
+		err = mem.aof.rotate()
 	}
-	mem.rwMutex.RLock()
-	defer mem.rwMutex.RUnlock()
-	byt, err := json.Marshal(mem.store)
+	mem.rUnlockAll()
 	if err != nil {
-		return &StatusCmd{baseCmd: baseCmd{err: err}}
+		return 0, err
 	}
-	err = disk.WriteToFile(byt)
-	return &StatusCmd{baseCmd: baseCmd{err: err}}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if err := disk.WriteToFile(byt); err != nil {
+		return 0, err
+	}
+	if mem.aof != nil {
+		if err := os.Remove(mem.aof.filename + ".1"); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	return changes, nil
 }
 
-func (mem *MemCache) load() error {
+// snapshotLocked 文件头之后每行一个 key，调用方持有所有分片的锁
+func (mem *MemCache) snapshotLocked(gen int64) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(headerLine(mem.codec, gen))
+	for _, sh := range mem.shards {
+		for k, v := range sh.store {
+			if v.Expired() {
+				continue
+			}
+			ev, err := encodeValue(mem.codec, v)
+			if err != nil {
+				return nil, fmt.Errorf("cache: encode key %s: %w", k, err)
+			}
+			byt, err := json.Marshal(aofRecord{Op: aofSet, Key: k, Val: ev})
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(byt)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// load 兼容没有文件头的旧版本 JSON 快照，返回快照中记录的日志代数
+func (mem *MemCache) load() (int64, error) {
 	if mem.filename == "" {
-		return nil
+		return 0, nil
 	}
 	values := make(map[string]WrapValue, 0)
 	disk, err := NewDisk(mem.filename)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	byt, err := disk.ReadFromFile()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if len(byt) == 0 {
-		return nil
+		return 0, nil
+	}
+	if isSnapshotHeader(byt) {
+		_, header, err := mem.replay(bytes.NewReader(byt), mem.filename, 0)
+		return header.Gen, err
 	}
 	if err := json.Unmarshal(byt, &values); err != nil {
-		return err
+		return 0, err
 	}
 
 	for k, v := range values {
 		if !v.Expired() {
+			restoreLegacyExpire(&v)
+			restoreValue(&v)
 			mem.set(k, v)
 		}
 	}
-	return nil
+	return 0, nil
+}
+
+// restoreValue 从 JSON 加载后，将集合类型恢复为内存中的结构
+func restoreValue(val *WrapValue) {
+	switch val.Kind {
+	case KindList:
+		l := newMemList().(*memList)
+		values, _ := val.Value.([]interface{})
+		for _, value := range values {
+			l.ll.PushBack(value)
+		}
+		val.Value = l
+	case KindZSet:
+		z := newZSet().(*zset)
+		if members, ok := val.Value.([]Z); ok {
+			for _, m := range members {
+				z.add(m.Member, m.Score)
+			}
+			val.Value = z
+			return
+		}
+		values, _ := val.Value.([]interface{})
+		for _, value := range values {
+			m, _ := value.(map[string]interface{})
+			member, _ := m["member"].(string)
+			score, _ := m["score"].(float64)
+			z.add(member, score)
+		}
+		val.Value = z
+	}
 }
 
 type Disk struct {
@@ -330,33 +1025,48 @@ func NewDisk(filename string) (*Disk, error) {
 	return &d, nil
 }
 
-// WriteToFile 如果之前文件存在，则删除
+// WriteToFile 先写入临时文件再重命名，写入过程中崩溃不会损坏之前的文件
 func (d *Disk) WriteToFile(data []byte) error {
-	if FilenameExists(d.filename) {
-		if err := os.Remove(d.filename); err != nil {
-			return err
-		}
-	} else {
+	if !FilenameExists(d.filename) {
 		dir := filepath.Dir(d.filename)
 		if err := os.MkdirAll(dir, os.FileMode(0666)); err != nil {
 			return err
 		}
 	}
 
-	file, err := os.OpenFile(d.filename, os.O_RDWR|os.O_CREATE, os.FileMode(0666))
+	tmp := d.filename + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0666))
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	_, err = file.Write(data)
-	if err != nil {
+	if _, err := file.Write(data); err != nil {
+		file.Close()
 		return err
 	}
-
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, d.filename); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(d.filename))
 	return nil
 }
 
+// syncDir 同步目录，保证重命名写入磁盘，部分系统不支持，忽略错误
+func syncDir(dir string) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	f.Sync()
+	f.Close()
+}
+
 // ReadFromFile
 func (d *Disk) ReadFromFile() ([]byte, error) {
 	data := make([]byte, 0)
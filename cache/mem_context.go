@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// MemCache 的操作不会阻塞，执行前检查 ctx 是否已经结束
+// 遍历 key 的操作见 KeysContext、ScanContext 和 SaveContext，阻塞操作见 BLPop
+
+func (mem *MemCache) GetContext(ctx context.Context, key string) *Cmd {
+	if err := ctx.Err(); err != nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.Get(key)
+}
+
+func (mem *MemCache) SetContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *StatusCmd {
+	if err := ctx.Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.Set(key, value, ttl)
+}
+
+func (mem *MemCache) DeleteContext(ctx context.Context, key string) *StatusCmd {
+	if err := ctx.Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.Delete(key)
+}
+
+func (mem *MemCache) ExpireContext(ctx context.Context, key string, ttl time.Duration) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.Expire(key, ttl)
+}
+
+func (mem *MemCache) ExpireAtContext(ctx context.Context, key string, tm time.Time) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.ExpireAt(key, tm)
+}
+
+func (mem *MemCache) PersistContext(ctx context.Context, key string) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.Persist(key)
+}
+
+func (mem *MemCache) TouchContext(ctx context.Context, key string, ttl time.Duration) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.Touch(key, ttl)
+}
+
+func (mem *MemCache) IncrByContext(ctx context.Context, key string, value int64, ttl time.Duration) *IntCmd {
+	if err := ctx.Err(); err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.IncrBy(key, value, ttl)
+}
+
+func (mem *MemCache) DecrByContext(ctx context.Context, key string, value int64, ttl time.Duration) *IntCmd {
+	if err := ctx.Err(); err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.DecrBy(key, value, ttl)
+}
+
+func (mem *MemCache) IncrByFloatContext(ctx context.Context, key string, value float64, ttl time.Duration) *FloatCmd {
+	if err := ctx.Err(); err != nil {
+		return &FloatCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.IncrByFloat(key, value, ttl)
+}
+
+func (mem *MemCache) SetNXContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.SetNX(key, value, ttl)
+}
+
+func (mem *MemCache) SetXXContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.SetXX(key, value, ttl)
+}
+
+func (mem *MemCache) GetSetContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *Cmd {
+	if err := ctx.Err(); err != nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.GetSet(key, value, ttl)
+}
+
+func (mem *MemCache) CompareAndSwapContext(ctx context.Context, key string, old, new interface{}, ttl time.Duration) *BoolCmd {
+	if err := ctx.Err(); err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.CompareAndSwap(key, old, new, ttl)
+}
+
+func (mem *MemCache) MGetContext(ctx context.Context, keys ...string) *MultiCmd {
+	if err := ctx.Err(); err != nil {
+		return &MultiCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.MGet(keys...)
+}
+
+func (mem *MemCache) MSetContext(ctx context.Context, values map[string]interface{}, ttl time.Duration) *StatusCmd {
+	if err := ctx.Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.MSet(values, ttl)
+}
+
+func (mem *MemCache) MDeleteContext(ctx context.Context, keys ...string) *IntCmd {
+	if err := ctx.Err(); err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.MDelete(keys...)
+}
+
+func (mem *MemCache) FlushAllContext(ctx context.Context) *StatusCmd {
+	if err := ctx.Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return mem.FlushAll()
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"time"
+)
+
+// Expire 修改过期时间，不修改值和标签，key 不存在时返回 false
+// 与 redis 一致，ttl <= 0 时删除 key，取消过期时间使用 Persist
+func (mem *MemCache) Expire(key string, ttl time.Duration) *BoolCmd {
+	return mem.expire(key, false, func(val *WrapValue) bool {
+		val.ExpiredTime = time.Now().Add(ttl)
+		return true
+	})
+}
+
+// ExpireAt tm 已经过去时删除 key，零值同样视为已经过去
+func (mem *MemCache) ExpireAt(key string, tm time.Time) *BoolCmd {
+	if tm.IsZero() {
+		tm = time.Unix(0, 0)
+	}
+	return mem.expire(key, false, func(val *WrapValue) bool {
+		val.ExpiredTime = tm
+		return true
+	})
+}
+
+// Persist 取消过期时间，key 不存在或没有过期时间时返回 false
+func (mem *MemCache) Persist(key string) *BoolCmd {
+	return mem.expire(key, false, func(val *WrapValue) bool {
+		if val.Persistent() {
+			return false
+		}
+		val.SetExpiredTime(-1)
+		return true
+	})
+}
+
+// Touch 与 Expire 相同，同时算作一次访问，用于延长会话等场景
+func (mem *MemCache) Touch(key string, ttl time.Duration) *BoolCmd {
+	return mem.expire(key, true, func(val *WrapValue) bool {
+		val.ExpiredTime = time.Now().Add(ttl)
+		return true
+	})
+}
+
+// expire 在分片写锁内修改过期时间，fn 返回 false 时不修改
+func (mem *MemCache) expire(key string, touch bool, fn func(val *WrapValue) bool) *BoolCmd {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	defer mem.unlock(sh)
+	old, ok := sh.store[key]
+	if !ok {
+		return &BoolCmd{}
+	}
+	if old.Expired() {
+		mem.removeLocked(sh, key, old, RemoveExpired)
+		return &BoolCmd{}
+	}
+	val := old
+	if !fn(&val) {
+		return &BoolCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}}
+	}
+	if val.Expired() {
+		// 传入修改前的值，回调的原因为主动删除
+		mem.removeLocked(sh, key, old, RemoveDeleted)
+		return &BoolCmd{value: true}
+	}
+	sh.store[key] = val
+	mem.trackExpireLocked(sh, key, val)
+	mem.logSetLocked(key, val)
+	if sh.evictor != nil {
+		sh.evictor.expire(key, val)
+		if touch {
+			sh.evictor.access(key)
+		}
+	}
+	return &BoolCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: true}
+}
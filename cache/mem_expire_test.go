@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemCache_Expire(t *testing.T) {
+	memCache := NewMemCache()
+	memCache.SetWithTags("k1", "v1", time.Minute, "t")
+
+	if cmd := memCache.Expire("k1", time.Hour); !cmd.Val() || cmd.TTL() <= time.Minute {
+		t.Fatal(cmd.Val(), cmd.TTL())
+	}
+	if getCmd := memCache.Get("k1"); getCmd.ValString() != "v1" || getCmd.TTL() <= time.Minute {
+		t.Fatal(getCmd.ValString(), getCmd.TTL())
+	}
+	if cmd := memCache.Expire("none", time.Hour); cmd.Val() || cmd.Exists() {
+		t.Fatal("none should not exist")
+	}
+
+	at := time.Now().Add(2 * time.Hour)
+	if cmd := memCache.ExpireAt("k1", at); !cmd.Val() || cmd.TTL() <= time.Hour {
+		t.Fatal(cmd.TTL())
+	}
+
+	if cmd := memCache.Persist("k1"); !cmd.Val() {
+		t.Fatal("persist should remove ttl")
+	}
+	if cmd := memCache.Persist("k1"); cmd.Val() || !cmd.Exists() {
+		t.Fatal("k1 has no ttl")
+	}
+
+	if cmd := memCache.Touch("k1", time.Millisecond); !cmd.Val() {
+		t.Fatal("touch should set ttl")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if memCache.Get("k1").Exists() {
+		t.Fatal("k1 should be expired")
+	}
+
+	// ttl <= 0 删除 key，标签一起删除
+	memCache.SetWithTags("k2", "v2", -1, "t")
+	if cmd := memCache.Expire("k2", 0); !cmd.Val() || cmd.Exists() {
+		t.Fatal(cmd.Val(), cmd.Exists())
+	}
+	if memCache.Get("k2").Exists() {
+		t.Fatal("k2 should be deleted")
+	}
+	if n := memCache.InvalidateTag("t").Val(); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestMemCache_ExpireFarFuture(t *testing.T) {
+	memCache := NewMemCache()
+	memCache.Set("k1", "v1", -1)
+
+	// 超过 50 年的过期时间不能当作没有过期时间
+	at := time.Now().AddDate(60, 0, 0)
+	if cmd := memCache.ExpireAt("k1", at); !cmd.Val() || cmd.TTL() < 59*365*24*time.Hour {
+		t.Fatal(cmd.TTL())
+	}
+	if cmd := memCache.Persist("k1"); !cmd.Val() {
+		t.Fatal("persist should remove ttl")
+	}
+	if ttl := memCache.Get("k1").TTL(); ttl != -1 {
+		t.Fatal(ttl)
+	}
+
+	// 零值视为已经过去
+	if cmd := memCache.ExpireAt("k1", time.Time{}); !cmd.Val() || memCache.Get("k1").Exists() {
+		t.Fatal("k1 should be deleted")
+	}
+}
+
+func TestMemCache_ExpireEviction(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.MaxEntries = 2
+	opt.Eviction = EvictionTTL
+	memCache := NewMemCache(opt)
+	memCache.Set("k1", 1, time.Minute)
+	memCache.Set("k2", 2, time.Hour)
+	// 修改过期时间后按新的过期时间淘汰
+	memCache.Expire("k1", 2*time.Hour)
+	memCache.Set("k3", 3, -1)
+	if memCache.Get("k2").Exists() || !memCache.Get("k1").Exists() {
+		t.Fatal("k2 should be evicted")
+	}
+
+	opt.Eviction = EvictionLRU
+	memCache = NewMemCache(opt)
+	memCache.Set("k1", 1, -1)
+	memCache.Set("k2", 2, -1)
+	// Expire 不算访问，Touch 算访问
+	memCache.Expire("k1", time.Hour)
+	memCache.Set("k3", 3, -1)
+	if memCache.Get("k1").Exists() {
+		t.Fatal("k1 should be evicted")
+	}
+	memCache.Touch("k2", time.Hour)
+	memCache.Set("k4", 4, -1)
+	if !memCache.Get("k2").Exists() || memCache.Get("k3").Exists() {
+		t.Fatal("k3 should be evicted")
+	}
+}
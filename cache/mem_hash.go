@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// collectionLocked 返回 key 对应的集合类型的值，不存在或已过期时用 create 新建，调用方持有分片写锁
+// 新建时不记录日志，修改后需要调用 saveCollectionLocked
+func (mem *MemCache) collectionLocked(sh *memShard, key string, kind ValueKind, create func() interface{}) (WrapValue, error) {
+	val, ok := sh.store[key]
+	if ok && val.Expired() {
+		mem.removeLocked(sh, key, val, RemoveExpired)
+		ok = false
+	}
+	if ok {
+		if val.Kind != kind {
+			return WrapValue{}, ErrWrongType
+		}
+		return val, nil
+	}
+
+	val = WrapValue{
+		Value: create(),
+		Size:  mem.sizeOf(key, ""),
+		Kind:  kind,
+	}
+	val.SetExpiredTime(-1)
+	if err := mem.storeLocked(sh, key, val); err != nil {
+		return WrapValue{}, err
+	}
+	return val, nil
+}
+
+// saveCollectionLocked 保存修改后的集合，与 redis 一致，没有元素时删除 key
+// op 和 args 为追加日志中的修改，op 为空表示没有修改
+func (mem *MemCache) saveCollectionLocked(sh *memShard, key string, val WrapValue, length int, op string, args interface{}) {
+	if length == 0 {
+		// val 已经减去了删除的元素占用的容量
+		mem.removeLocked(sh, key, val, removeSilent)
+		return
+	}
+	sh.store[key] = val
+	if op != "" {
+		mem.logCollectionLocked(key, op, args)
+		atomic.AddInt64(&mem.stats.sets, 1)
+	}
+	if sh.evictor != nil {
+		sh.evictor.add(key, val)
+	}
+}
+
+// readCollectionLocked 读取集合类型的值并记录访问，调用方持有分片读锁
+func readCollectionLocked(sh *memShard, key string, kind ValueKind) (WrapValue, bool, error) {
+	val, ok := sh.store[key]
+	if !ok || val.Expired() {
+		return WrapValue{}, false, nil
+	}
+	if val.Kind != kind {
+		return WrapValue{}, false, ErrWrongType
+	}
+	if sh.evictor != nil {
+		sh.evictor.access(key)
+	}
+	return val, true, nil
+}
+
+func newHash() interface{} {
+	return make(map[string]interface{})
+}
+
+// HSet 返回 field 是否是新增的
+func (mem *MemCache) HSet(key, field string, value interface{}) *BoolCmd {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	defer mem.unlock(sh)
+	val, err := mem.collectionLocked(sh, key, KindHash, newHash)
+	if err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	fields := val.Value.(map[string]interface{})
+	old, exists := fields[field]
+
+	addSize := mem.sizeOf(field, value)
+	if exists {
+		addSize -= mem.sizeOf(field, old)
+	}
+	if err := mem.growLocked(sh, key, addSize); err != nil {
+		mem.saveCollectionLocked(sh, key, val, len(fields), "", nil)
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	fields[field] = value
+	val.Size += addSize
+	mem.saveCollectionLocked(sh, key, val, len(fields), aofHSet, map[string]interface{}{field: value})
+	return &BoolCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: !exists}
+}
+
+func (mem *MemCache) HGet(key, field string) *Cmd {
+	sh := mem.shard(key)
+	sh.rwMutex.RLock()
+	defer sh.rwMutex.RUnlock()
+	val, ok, err := readCollectionLocked(sh, key, KindHash)
+	if err != nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+	if !ok {
+		return &Cmd{}
+	}
+	value, ok := val.Value.(map[string]interface{})[field]
+	if !ok {
+		return &Cmd{}
+	}
+	return &Cmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: value}
+}
+
+// HGetAll 返回所有 field 的副本
+func (mem *MemCache) HGetAll(key string) *MapCmd {
+	sh := mem.shard(key)
+	sh.rwMutex.RLock()
+	defer sh.rwMutex.RUnlock()
+	val, ok, err := readCollectionLocked(sh, key, KindHash)
+	if err != nil {
+		return &MapCmd{baseCmd: baseCmd{err: err}}
+	}
+	result := make(map[string]interface{})
+	if !ok {
+		return &MapCmd{value: result}
+	}
+	for field, value := range val.Value.(map[string]interface{}) {
+		result[field] = value
+	}
+	return &MapCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: result}
+}
+
+// HDel 返回删除的 field 数量
+func (mem *MemCache) HDel(key string, fields ...string) *IntCmd {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	defer mem.unlock(sh)
+	val, ok, err := readCollectionLocked(sh, key, KindHash)
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	if !ok {
+		return &IntCmd{}
+	}
+	values := val.Value.(map[string]interface{})
+	deleted := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if old, ok := values[field]; ok {
+			delete(values, field)
+			size := mem.sizeOf(field, old)
+			mem.reserve(sh, -size, 0)
+			val.Size -= size
+			deleted = append(deleted, field)
+		}
+	}
+	n := int64(len(deleted))
+	if n == 0 {
+		mem.saveCollectionLocked(sh, key, val, len(values), "", nil)
+	} else {
+		mem.saveCollectionLocked(sh, key, val, len(values), aofHDel, deleted)
+	}
+	return &IntCmd{value: n}
+}
+
+func (mem *MemCache) HIncrBy(key, field string, incr int64) *IntCmd {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	defer mem.unlock(sh)
+	val, err := mem.collectionLocked(sh, key, KindHash, newHash)
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	fields := val.Value.(map[string]interface{})
+	n := int64(0)
+	old, exists := fields[field]
+	if exists {
+		if n, err = toInt64(old); err != nil {
+			return &IntCmd{baseCmd: baseCmd{err: ErrNotInteger}}
+		}
+	}
+	if (incr > 0 && n > math.MaxInt64-incr) || (incr < 0 && n < math.MinInt64-incr) {
+		mem.saveCollectionLocked(sh, key, val, len(fields), "", nil)
+		return &IntCmd{baseCmd: baseCmd{err: ErrNotInteger}}
+	}
+	n += incr
+
+	addSize := mem.sizeOf(field, n)
+	if exists {
+		addSize -= mem.sizeOf(field, old)
+	}
+	if err := mem.growLocked(sh, key, addSize); err != nil {
+		mem.saveCollectionLocked(sh, key, val, len(fields), "", nil)
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	fields[field] = n
+	val.Size += addSize
+	// 记录增加后的值，重放结果与 HSet 相同
+	mem.saveCollectionLocked(sh, key, val, len(fields), aofHSet, map[string]interface{}{field: n})
+	return &IntCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: n}
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+var (
+	_ HashCache = (*MemCache)(nil)
+	_ HashCache = (*RedisCache)(nil)
+)
+
+func TestMemCache_Hash(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Filename = "./cache_hash.bak"
+	defer os.Remove(opt.Filename)
+	memCache := NewMemCache(opt)
+
+	if setCmd := memCache.HSet("user:1", "name", "tom"); setCmd.Error() != nil || !setCmd.Val() {
+		t.Fatal(setCmd.Val(), setCmd.Error())
+	}
+	if setCmd := memCache.HSet("user:1", "name", "jerry"); setCmd.Error() != nil || setCmd.Val() {
+		t.Fatal("name should not be new field")
+	}
+	if getCmd := memCache.HGet("user:1", "name"); !getCmd.Exists() || getCmd.ValString() != "jerry" {
+		t.Fatal(getCmd.ValString())
+	}
+	if getCmd := memCache.HGet("user:1", "age"); getCmd.Exists() {
+		t.Fatal("age should not exists")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			memCache.HIncrBy("user:1", "visits", 1)
+		}()
+	}
+	wg.Wait()
+	if n, err := memCache.HGet("user:1", "visits").Int64(); err != nil || n != 100 {
+		t.Fatal(n, err)
+	}
+	if incrCmd := memCache.HIncrBy("user:1", "name", 1); incrCmd.Error() != ErrNotInteger {
+		t.Fatal(incrCmd.Error())
+	}
+
+	// 数据类型不同
+	if getCmd := memCache.Get("user:1"); getCmd.Error() != ErrWrongType {
+		t.Fatal(getCmd.Error())
+	}
+	memCache.Set("string", "abc", -1)
+	if setCmd := memCache.HSet("string", "name", "tom"); setCmd.Error() != ErrWrongType {
+		t.Fatal(setCmd.Error())
+	}
+
+	if err := memCache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	memCache = NewMemCache(opt)
+	all := memCache.HGetAll("user:1").Val()
+	if len(all) != 2 || all["name"] != "jerry" {
+		t.Fatal(all)
+	}
+	if n, err := memCache.HGet("user:1", "visits").Int64(); err != nil || n != 100 {
+		t.Fatal(n, err)
+	}
+
+	if delCmd := memCache.HDel("user:1", "name", "not_exists"); delCmd.Val() != 1 {
+		t.Fatal(delCmd.Val())
+	}
+	memCache.HDel("user:1", "visits")
+	if keys := memCache.Keys("user:").Val(); len(keys) != 0 {
+		t.Fatal("empty hash should be deleted", keys)
+	}
+}
+
+func TestMemCache_HashSize(t *testing.T) {
+	opt := NewDefaultOptions()
+	// key 占 2，每个 field 加 value 占 2
+	opt.Size = 7
+	memCache := NewMemCache(opt)
+	if setCmd := memCache.HSet("h1", "a", "1"); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if setCmd := memCache.HSet("h1", "b", "2"); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if setCmd := memCache.HSet("h1", "c", "3"); setCmd.Error() != ErrKeysOverCapacity {
+		t.Fatal("should over size")
+	}
+	if setCmd := memCache.HSet("h2", "c", "3"); setCmd.Error() != ErrKeysOverCapacity {
+		t.Fatal("should over size")
+	}
+	if memCache.HGetAll("h2").Exists() {
+		t.Fatal("h2 should not be created")
+	}
+
+	memCache.HDel("h1", "a")
+	if setCmd := memCache.HSet("h1", "c", "3"); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+}
+
+func TestMemCache_HashDrainSize(t *testing.T) {
+	opt := NewDefaultOptions()
+	// key 占 1，每个 field 加 value 占 2
+	opt.Size = 6
+	memCache := NewMemCache(opt)
+	// 删除所有 field 后 key 被删除，容量全部释放
+	for i := 0; i < 3; i++ {
+		memCache.HSet("h", "a", "1")
+		memCache.HSet("h", "b", "2")
+		memCache.HDel("h", "a")
+		memCache.HDel("h", "b")
+		if stats := memCache.Stats().Val(); stats.Bytes != 0 || stats.Keys != 0 {
+			t.Fatal(stats)
+		}
+	}
+	memCache.HSet("h", "a", "1")
+	memCache.HSet("h", "b", "2")
+	if setCmd := memCache.HSet("h", "c", "3"); setCmd.Error() != ErrKeysOverCapacity {
+		t.Fatal("should over size")
+	}
+}
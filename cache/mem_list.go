@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// memList 双向链表，两端的 push/pop 都是 O(1)
+type memList struct {
+	ll *list.List
+}
+
+func newMemList() interface{} {
+	return &memList{ll: list.New()}
+}
+
+// MarshalJSON 保存为数组
+func (l *memList) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.values(0, l.ll.Len()-1))
+}
+
+// values 返回 [start, stop] 范围内的元素
+func (l *memList) values(start, stop int) []interface{} {
+	values := make([]interface{}, 0)
+	i := 0
+	for e := l.ll.Front(); e != nil && i <= stop; e = e.Next() {
+		if i >= start {
+			values = append(values, e.Value)
+		}
+		i++
+	}
+	return values
+}
+
+// listRange 将 redis 风格的下标（负数从尾部计算）转换为 [start, stop]，范围为空时返回 false
+func listRange(start, stop int64, length int) (int, int, bool) {
+	n := int64(length)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop || start >= n {
+		return 0, 0, false
+	}
+	return int(start), int(stop), true
+}
+
+// listWaiters 阻塞等待 list 写入的调用方
+type listWaiters struct {
+	mutex   sync.Mutex
+	waiters map[string]map[chan struct{}]struct{}
+}
+
+func newListWaiters() *listWaiters {
+	return &listWaiters{
+		waiters: make(map[string]map[chan struct{}]struct{}),
+	}
+}
+
+func (w *listWaiters) add(keys []string, ch chan struct{}) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, key := range keys {
+		if w.waiters[key] == nil {
+			w.waiters[key] = make(map[chan struct{}]struct{})
+		}
+		w.waiters[key][ch] = struct{}{}
+	}
+}
+
+func (w *listWaiters) remove(keys []string, ch chan struct{}) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, key := range keys {
+		delete(w.waiters[key], ch)
+		if len(w.waiters[key]) == 0 {
+			delete(w.waiters, key)
+		}
+	}
+}
+
+// notify 通知等待 key 的调用方，不会阻塞
+func (w *listWaiters) notify(key string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for ch := range w.waiters[key] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (mem *MemCache) LPush(key string, values ...interface{}) *IntCmd {
+	return mem.push(key, true, values)
+}
+
+func (mem *MemCache) RPush(key string, values ...interface{}) *IntCmd {
+	return mem.push(key, false, values)
+}
+
+func (mem *MemCache) push(key string, front bool, values []interface{}) *IntCmd {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	val, err := mem.collectionLocked(sh, key, KindList, newMemList)
+	if err != nil {
+		mem.unlock(sh)
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	l := val.Value.(*memList)
+
+	addSize := int64(0)
+	for _, value := range values {
+		addSize += mem.sizeOf("", value)
+	}
+	if err := mem.growLocked(sh, key, addSize); err != nil {
+		mem.saveCollectionLocked(sh, key, val, l.ll.Len(), "", nil)
+		mem.unlock(sh)
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	for _, value := range values {
+		if front {
+			l.ll.PushFront(value)
+		} else {
+			l.ll.PushBack(value)
+		}
+	}
+	val.Size += addSize
+	op := aofRPush
+	if front {
+		op = aofLPush
+	}
+	mem.saveCollectionLocked(sh, key, val, l.ll.Len(), op, values)
+	n := l.ll.Len()
+	ttl := val.TTL()
+	mem.unlock(sh)
+
+	if n > 0 {
+		mem.listWaiters.notify(key)
+	}
+	return &IntCmd{baseCmd: baseCmd{exists: n > 0, ttl: ttl}, value: int64(n)}
+}
+
+func (mem *MemCache) LPop(key string) *Cmd {
+	return mem.pop(key, true)
+}
+
+func (mem *MemCache) RPop(key string) *Cmd {
+	return mem.pop(key, false)
+}
+
+func (mem *MemCache) pop(key string, front bool) *Cmd {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	defer mem.unlock(sh)
+	val, ok, err := readCollectionLocked(sh, key, KindList)
+	if err != nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+	if !ok {
+		return &Cmd{}
+	}
+	l := val.Value.(*memList)
+	e := l.ll.Back()
+	if front {
+		e = l.ll.Front()
+	}
+	l.ll.Remove(e)
+	size := mem.sizeOf("", e.Value)
+	mem.reserve(sh, -size, 0)
+	val.Size -= size
+	op := aofRPop
+	if front {
+		op = aofLPop
+	}
+	mem.saveCollectionLocked(sh, key, val, l.ll.Len(), op, nil)
+	return &Cmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: e.Value}
+}
+
+// BLPop 按顺序从第一个非空的 list 头部取出元素，都为空时阻塞等待
+// timeout 为 0 时一直等待，直到 ctx 结束，超时返回不存在
+func (mem *MemCache) BLPop(ctx context.Context, timeout time.Duration, keys ...string) *KeyValueCmd {
+	ch := make(chan struct{}, 1)
+	// 先注册再读取，避免错过读取后写入的通知
+	mem.listWaiters.add(keys, ch)
+	defer mem.listWaiters.remove(keys, ch)
+
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+	for {
+		for _, key := range keys {
+			cmd := mem.LPop(key)
+			if cmd.Error() != nil {
+				return &KeyValueCmd{Cmd: *cmd}
+			}
+			if cmd.Exists() {
+				return &KeyValueCmd{Cmd: *cmd, key: key}
+			}
+		}
+
+		select {
+		case <-ch:
+		case <-timeoutC:
+			return &KeyValueCmd{}
+		case <-ctx.Done():
+			return &KeyValueCmd{Cmd: Cmd{baseCmd: baseCmd{err: ctx.Err()}}}
+		}
+	}
+}
+
+// LRange 返回 [start, stop] 范围内的元素，负数表示从尾部开始计算
+func (mem *MemCache) LRange(key string, start, stop int64) *SliceCmd {
+	sh := mem.shard(key)
+	sh.rwMutex.RLock()
+	defer sh.rwMutex.RUnlock()
+	val, ok, err := readCollectionLocked(sh, key, KindList)
+	if err != nil {
+		return &SliceCmd{baseCmd: baseCmd{err: err}}
+	}
+	if !ok {
+		return &SliceCmd{value: []interface{}{}}
+	}
+	l := val.Value.(*memList)
+	values := []interface{}{}
+	if from, to, ok := listRange(start, stop, l.ll.Len()); ok {
+		values = l.values(from, to)
+	}
+	return &SliceCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: values}
+}
+
+// LTrim 只保留 [start, stop] 范围内的元素
+func (mem *MemCache) LTrim(key string, start, stop int64) *StatusCmd {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	defer mem.unlock(sh)
+	val, ok, err := readCollectionLocked(sh, key, KindList)
+	if err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	if !ok {
+		return &StatusCmd{value: StatusOK}
+	}
+	l := val.Value.(*memList)
+	from, to, ok := listRange(start, stop, l.ll.Len())
+	if !ok {
+		from, to = l.ll.Len(), l.ll.Len()
+	}
+	length := l.ll.Len()
+	i := 0
+	for e := l.ll.Front(); e != nil; i++ {
+		next := e.Next()
+		if i < from || i > to {
+			l.ll.Remove(e)
+			size := mem.sizeOf("", e.Value)
+			mem.reserve(sh, -size, 0)
+			val.Size -= size
+		}
+		e = next
+	}
+	if l.ll.Len() == length {
+		mem.saveCollectionLocked(sh, key, val, length, "", nil)
+	} else {
+		mem.saveCollectionLocked(sh, key, val, l.ll.Len(), aofLTrim, []int64{int64(from), int64(to)})
+	}
+	return &StatusCmd{value: StatusOK}
+}
+
+func (mem *MemCache) LLen(key string) *IntCmd {
+	sh := mem.shard(key)
+	sh.rwMutex.RLock()
+	defer sh.rwMutex.RUnlock()
+	val, ok, err := readCollectionLocked(sh, key, KindList)
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	if !ok {
+		return &IntCmd{}
+	}
+	return &IntCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: int64(val.Value.(*memList).ll.Len())}
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+var (
+	_ ListCache = (*MemCache)(nil)
+	_ ListCache = (*RedisCache)(nil)
+)
+
+func TestMemCache_List(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Filename = "./cache_list.bak"
+	defer os.Remove(opt.Filename)
+	memCache := NewMemCache(opt)
+
+	memCache.RPush("queue", 1, 2, 3)
+	if pushCmd := memCache.LPush("queue", 0); pushCmd.Error() != nil || pushCmd.Val() != 4 {
+		t.Fatal(pushCmd.Val(), pushCmd.Error())
+	}
+	if values := memCache.LRange("queue", 0, -1).Val(); len(values) != 4 || values[0] != 0 || values[3] != 3 {
+		t.Fatal(values)
+	}
+	if values := memCache.LRange("queue", -2, 10).Val(); len(values) != 2 || values[0] != 2 {
+		t.Fatal(values)
+	}
+	if popCmd := memCache.LPop("queue"); !popCmd.Exists() || popCmd.Val() != 0 {
+		t.Fatal(popCmd.Val())
+	}
+	if popCmd := memCache.RPop("queue"); !popCmd.Exists() || popCmd.Val() != 3 {
+		t.Fatal(popCmd.Val())
+	}
+	if getCmd := memCache.Get("queue"); getCmd.Error() != ErrWrongType {
+		t.Fatal(getCmd.Error())
+	}
+
+	// 最近浏览的 3 个
+	for i := 0; i < 10; i++ {
+		memCache.LPush("recent", i)
+		memCache.LTrim("recent", 0, 2)
+	}
+	if values := memCache.LRange("recent", 0, -1).Val(); len(values) != 3 || values[0] != 9 || values[2] != 7 {
+		t.Fatal(values)
+	}
+
+	if err := memCache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	memCache = NewMemCache(opt)
+	if n := memCache.LLen("recent").Val(); n != 3 {
+		t.Fatal(n)
+	}
+	if n, err := memCache.LPop("queue").Int64(); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+	memCache.LPop("queue")
+	if memCache.LPop("queue").Exists() || len(memCache.Keys("queue").Val()) != 0 {
+		t.Fatal("empty list should be deleted")
+	}
+}
+
+func TestMemCache_BLPop(t *testing.T) {
+	memCache := NewMemCache(NewDefaultOptions())
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		memCache.RPush("q2", "job")
+	}()
+	popCmd := memCache.BLPop(context.Background(), time.Second, "q1", "q2")
+	if popCmd.Error() != nil || !popCmd.Exists() || popCmd.Key() != "q2" || popCmd.ValString() != "job" {
+		t.Fatal(popCmd.Key(), popCmd.Val(), popCmd.Error())
+	}
+
+	start := time.Now()
+	if popCmd := memCache.BLPop(context.Background(), 50*time.Millisecond, "q1"); popCmd.Error() != nil || popCmd.Exists() {
+		t.Fatal("should timeout")
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatal("should wait timeout")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if popCmd := memCache.BLPop(ctx, 0, "q1"); popCmd.Error() != context.DeadlineExceeded {
+		t.Fatal(popCmd.Error())
+	}
+}
+
+func TestMemCache_ListSize(t *testing.T) {
+	opt := NewDefaultOptions()
+	// key 占 1，每个 int 元素占 8
+	opt.Size = 25
+	memCache := NewMemCache(opt)
+	if pushCmd := memCache.RPush("q", 1, 2, 3); pushCmd.Error() != nil {
+		t.Fatal(pushCmd.Error())
+	}
+	if pushCmd := memCache.RPush("q", 4); pushCmd.Error() != ErrKeysOverCapacity {
+		t.Fatal("should over size")
+	}
+	memCache.LPop("q")
+	if pushCmd := memCache.RPush("q", 4); pushCmd.Error() != nil {
+		t.Fatal(pushCmd.Error())
+	}
+}
+
+func TestMemCache_ListDrainSize(t *testing.T) {
+	opt := NewDefaultOptions()
+	// key 占 1，每个 int 元素占 8
+	opt.Size = 25
+	memCache := NewMemCache(opt)
+	// 取出或裁剪所有元素后 key 被删除，容量全部释放
+	for i := 0; i < 3; i++ {
+		memCache.RPush("q", 1, 2, 3)
+		memCache.LPop("q")
+		memCache.RPop("q")
+		memCache.LPop("q")
+		if stats := memCache.Stats().Val(); stats.Bytes != 0 || stats.Keys != 0 {
+			t.Fatal(stats)
+		}
+		memCache.RPush("q", 1, 2)
+		memCache.LTrim("q", 5, 10)
+		if stats := memCache.Stats().Val(); stats.Bytes != 0 || stats.Keys != 0 {
+			t.Fatal(stats)
+		}
+	}
+	if pushCmd := memCache.RPush("q", 1, 2, 3, 4); pushCmd.Error() != ErrKeysOverCapacity {
+		t.Fatal("should over size")
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"time"
+)
+
+// SetWithTags 写入并给 key 打上标签，覆盖 key 之前的标签
+// 之后通过 Set、SetNX、IncrBy 等写入时保留标签，与 redis 一致
+func (mem *MemCache) SetWithTags(key string, value interface{}, ttl time.Duration, tags ...string) *StatusCmd {
+	val := mem.newWrapValue(key, value)
+	val.SetExpiredTime(ttl)
+	val.Tags = uniqueTags(tags)
+
+	if err := mem.set(key, val); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &StatusCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: StatusOK}
+}
+
+// InvalidateTag 锁住所有分片后删除，返回删除的未过期 key 数量
+func (mem *MemCache) InvalidateTag(tag string) *IntCmd {
+	mem.lockAll()
+	defer mem.unlockAll()
+	n := int64(0)
+	for _, sh := range mem.shards {
+		for key := range sh.tags[tag] {
+			val := sh.store[key]
+			if !val.Expired() {
+				n++
+			}
+			mem.removeLocked(sh, key, val, RemoveDeleted)
+		}
+	}
+	return &IntCmd{value: n}
+}
+
+// keepTagsLocked 覆盖未过期的 key 时保留之前的标签，调用方持有分片写锁
+func keepTagsLocked(sh *memShard, key string, val *WrapValue) {
+	if old, ok := sh.store[key]; ok && !old.Expired() {
+		val.Tags = old.Tags
+	}
+}
+
+// uniqueTags 去掉重复的标签
+func uniqueTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		result = append(result, tag)
+	}
+	return result
+}
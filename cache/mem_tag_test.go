@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+var (
+	_ TagCache = (*MemCache)(nil)
+	_ TagCache = (*RedisCache)(nil)
+)
+
+func TestMemCache_Tags(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Filename = "./cache_tag.bak"
+	opt.Shards = 4
+	defer os.Remove(opt.Filename)
+	memCache := NewMemCache(opt)
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("user:42:page:%d", i)
+		if setCmd := memCache.SetWithTags(key, i, time.Minute, "user:42", "pages", "pages"); setCmd.Error() != nil {
+			t.Fatal(setCmd.Error())
+		}
+	}
+	memCache.SetWithTags("user:7:profile", "tom", -1, "user:7", "pages")
+	memCache.SetWithTags("expired", 1, time.Millisecond, "user:42")
+	time.Sleep(5 * time.Millisecond)
+
+	// 不再带有标签的 key 不会被删除
+	memCache.SetWithTags("user:42:page:0", "no tag", -1)
+	// 原子增减保留标签
+	memCache.SetWithTags("user:42:visits", 1, -1, "user:42")
+	memCache.IncrBy("user:42:visits", 1, 0)
+
+	if err := memCache.Save().Error(); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewMemCache(opt)
+
+	for _, c := range []*MemCache{memCache, loaded} {
+		if cmd := c.InvalidateTag("user:42"); cmd.Val() != 10 {
+			t.Fatal(cmd.Val())
+		}
+		if keysCmd := c.Keys(""); len(keysCmd.Val()) != 2 {
+			t.Fatal(keysCmd.Val())
+		}
+		if !c.Get("user:42:page:0").Exists() || !c.Get("user:7:profile").Exists() {
+			t.Fatal("keys without tag should exist")
+		}
+		if cmd := c.InvalidateTag("user:42"); cmd.Val() != 0 {
+			t.Fatal(cmd.Val())
+		}
+		if cmd := c.InvalidateTag("pages"); cmd.Val() != 1 {
+			t.Fatal(cmd.Val())
+		}
+		if keysCmd := c.Keys(""); len(keysCmd.Val()) != 1 {
+			t.Fatal(keysCmd.Val())
+		}
+	}
+
+	// 删除后标签索引同步清除
+	memCache.SetWithTags("a", 1, -1, "t")
+	memCache.Delete("a")
+	memCache.Set("a", 2, -1)
+	if cmd := memCache.InvalidateTag("t"); cmd.Val() != 0 || !memCache.Get("a").Exists() {
+		t.Fatal(cmd.Val())
+	}
+	for _, sh := range memCache.shards {
+		if len(sh.tags) != 0 {
+			t.Fatal(sh.tags)
+		}
+	}
+}
+
+// Set 覆盖 key 时保留标签，MemCache 与 redis 一致
+func TestCache_SetKeepTags(t *testing.T) {
+	rc, _ := newTestRedisCache(t)
+	caches := map[string]interface {
+		Cache
+		TagCache
+	}{"mem": NewMemCache(), "redis": rc}
+
+	for name, c := range caches {
+		c.SetWithTags("k1", 1, -1, "t")
+		c.SetWithTags("k2", 1, -1, "t")
+		c.SetWithTags("k3", 1, -1, "t")
+		c.Set("k1", 2, -1)
+		c.SetXX("k2", 2, -1)
+		c.IncrBy("k3", 1, -1)
+		c.Set("other", 1, -1)
+
+		if cmd := c.InvalidateTag("t"); cmd.Val() != 3 {
+			t.Fatal(name, cmd.Val())
+		}
+		if keys := c.Keys("").Val(); len(keys) != 1 || keys[0] != "other" {
+			t.Fatal(name, keys)
+		}
+	}
+}
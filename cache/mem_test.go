@@ -1,7 +1,11 @@
 package cache
 
 import (
+	"fmt"
+	"math"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -71,3 +75,369 @@ func TestMemCache_Save(t *testing.T) {
 
 	os.Remove("./cache.bak")
 }
+
+func TestMemCache_Eviction(t *testing.T) {
+	newCache := func(policy EvictionPolicy) *MemCache {
+		opt := NewDefaultOptions()
+		// 每个 key 占 4 byte，可以存 3 个
+		opt.Size = 12
+		opt.Eviction = policy
+		return NewMemCache(opt)
+	}
+
+	// LRU: k1 被访问过，淘汰 k2
+	memCache := newCache(EvictionLRU)
+	memCache.Set("k1", "v1", -1)
+	memCache.Set("k2", "v2", -1)
+	memCache.Set("k3", "v3", -1)
+	memCache.Get("k1")
+	if setCmd := memCache.Set("k4", "v4", -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if memCache.Get("k2").Exists() || !memCache.Get("k1").Exists() {
+		t.Fatal("lru should evict k2")
+	}
+
+	// LFU: k1 k3 访问次数多，淘汰 k2
+	memCache = newCache(EvictionLFU)
+	memCache.Set("k1", "v1", -1)
+	memCache.Set("k2", "v2", -1)
+	memCache.Set("k3", "v3", -1)
+	memCache.Get("k1")
+	memCache.Get("k3")
+	memCache.Get("k1")
+	if setCmd := memCache.Set("k4", "v4", -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if memCache.Get("k2").Exists() || !memCache.Get("k3").Exists() {
+		t.Fatal("lfu should evict k2")
+	}
+
+	// TTL: 最先过期的 k3 被淘汰
+	memCache = newCache(EvictionTTL)
+	memCache.Set("k1", "v1", -1)
+	memCache.Set("k2", "v2", time.Hour)
+	memCache.Set("k3", "v3", time.Minute)
+	if setCmd := memCache.Set("k4", "v4", -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if memCache.Get("k3").Exists() || !memCache.Get("k2").Exists() {
+		t.Fatal("ttl should evict k3")
+	}
+
+	// 覆盖写入变大时，淘汰其他 key
+	if setCmd := memCache.Set("k4", "v4444", -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if memCache.Get("k2").Exists() || !memCache.Get("k4").Exists() {
+		t.Fatal("ttl should evict k2")
+	}
+	if memCache.EvictedCount() != 2 {
+		t.Fatal(memCache.EvictedCount())
+	}
+
+	// 超过总容量，淘汰也放不下
+	if setCmd := memCache.Set("30", "123456789012345678901234567890", -1); setCmd.Error() != ErrKeysOverCapacity {
+		t.Fatal("should over size")
+	}
+}
+
+func TestMemCache_EvictionCollection(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Size = 12
+	opt.Eviction = EvictionLRU
+	memCache := NewMemCache(opt)
+
+	// h 占 3 byte，读取集合也算作访问，淘汰 k1
+	memCache.HSet("h", "f", "v")
+	memCache.Set("k1", "v1", -1)
+	memCache.Set("k2", "v2", -1)
+	memCache.HGet("h", "f")
+	if setCmd := memCache.Set("k3", "v3", -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if memCache.Get("k1").Exists() || !memCache.HGet("h", "f").Exists() {
+		t.Fatal("lru should evict k1")
+	}
+
+	// 集合变大时淘汰其他 key，写入也算作访问
+	memCache.Get("k2")
+	if setCmd := memCache.HSet("h", "g", "v"); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if memCache.Get("k3").Exists() || !memCache.Get("k2").Exists() {
+		t.Fatal("lru should evict k3")
+	}
+	memCache.Get("k2")
+	memCache.HSet("h", "f", "w")
+	if setCmd := memCache.Set("k4", "v4", -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if memCache.Get("k2").Exists() || len(memCache.HGetAll("h").Val()) != 2 {
+		t.Fatal("lru should evict k2")
+	}
+}
+
+func TestMemCache_Shards(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Shards = 8
+	opt.Size = 30
+	opt.Filename = "./cache_shards.bak"
+	defer os.Remove(opt.Filename)
+	memCache := NewMemCache(opt)
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("k%d", i)
+		if setCmd := memCache.Set(key, "v", -1); setCmd.Error() != nil {
+			t.Fatal(setCmd.Error())
+		}
+	}
+	// 容量按所有分片总量计算
+	if setCmd := memCache.Set("k10", "v", -1); setCmd.Error() != ErrKeysOverCapacity {
+		t.Fatal("should over size")
+	}
+	if keys := memCache.Keys("k").Val(); len(keys) != 10 {
+		t.Fatal(keys)
+	}
+	if err := memCache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	memCache1 := NewMemCache(opt)
+	if keys := memCache1.Keys("").Val(); len(keys) != 10 {
+		t.Fatal(keys)
+	}
+	memCache1.FlushAll()
+	if keys := memCache1.Keys("").Val(); len(keys) != 0 {
+		t.Fatal(keys)
+	}
+	if setCmd := memCache1.Set("k10", "v", -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+}
+
+func TestMemCache_ShardsEviction(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Shards = 4
+	opt.Size = 40
+	opt.Eviction = EvictionLRU
+	memCache := NewMemCache(opt)
+	// 找到同一个分片的两个 key 和另一个分片的 key，key 长度都为 2
+	var same, other []string
+	for i := 10; i < 100 && (len(same) < 2 || len(other) < 1); i++ {
+		key := fmt.Sprintf("%d", i)
+		if shardIndex(key, opt.Shards) == 0 {
+			same = append(same, key)
+		} else if len(other) == 0 {
+			other = append(other, key)
+		}
+	}
+	a, b, c := same[0], same[1], other[0]
+
+	// 每个分片容量为 10
+	memCache.Set(a, "v", -1)
+	memCache.Set(b, "v", -1)
+	// 覆盖时淘汰分片内其他的 key
+	if setCmd := memCache.Set(a, "12345678", -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if memCache.Get(b).Exists() || memCache.Get(a).ValString() != "12345678" {
+		t.Fatal("should evict", b)
+	}
+	// 超过分片容量时写入失败，保留旧值
+	if setCmd := memCache.Set(a, "123456789", -1); setCmd.Error() != ErrKeysOverCapacity {
+		t.Fatal("should over size")
+	}
+	if getCmd := memCache.Get(a); getCmd.ValString() != "12345678" {
+		t.Fatal(getCmd.ValString())
+	}
+	// 其他分片不受影响
+	if setCmd := memCache.Set(c, "12345678", -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if stats := memCache.Stats().Val(); stats.Keys != 2 || stats.Bytes != 20 {
+		t.Fatal(stats)
+	}
+}
+
+func TestMemCache_ShardsOverLimit(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Shards = 8
+	opt.MaxEntries = 4
+	opt.Eviction = EvictionLRU
+	memCache := NewMemCache(opt)
+	// 分片数量减少到 MaxEntries，总数不超出限制
+	if len(memCache.shards) != 4 {
+		t.Fatal(len(memCache.shards))
+	}
+	for i := 0; i < 100; i++ {
+		memCache.Set(fmt.Sprintf("k%d", i), i, -1)
+	}
+	if stats := memCache.Stats().Val(); stats.Keys != 4 {
+		t.Fatal(stats)
+	}
+}
+
+func benchmarkMemCacheParallel(b *testing.B, shards int) {
+	opt := NewDefaultOptions()
+	opt.Shards = shards
+	memCache := NewMemCache(opt)
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key:%d", i)
+		memCache.Set(keys[i], i, -1)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			// 读多写少
+			if i%10 == 0 {
+				memCache.Set(key, i, time.Minute)
+			} else {
+				memCache.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkMemCache_Parallel(b *testing.B) {
+	b.Run("shards=1", func(b *testing.B) { benchmarkMemCacheParallel(b, 1) })
+	b.Run("shards=16", func(b *testing.B) { benchmarkMemCacheParallel(b, 16) })
+	b.Run("shards=64", func(b *testing.B) { benchmarkMemCacheParallel(b, 64) })
+}
+
+func TestMemCache_IncrBy(t *testing.T) {
+	memCache := NewMemCache(NewDefaultOptions())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			memCache.IncrBy("counter", 2, time.Minute)
+			memCache.DecrBy("counter", 1, time.Minute)
+		}()
+	}
+	wg.Wait()
+	getCmd := memCache.Get("counter")
+	if n, err := getCmd.Int64(); err != nil || n != 100 {
+		t.Fatal(n, err)
+	}
+	if getCmd.TTL() > time.Minute || getCmd.TTL() < 50*time.Second {
+		t.Fatal(getCmd.TTL())
+	}
+
+	if incrCmd := memCache.IncrByFloat("counter", 0.5, -1); incrCmd.Error() != nil || incrCmd.Val() != 100.5 {
+		t.Fatal(incrCmd.Val(), incrCmd.Error())
+	}
+	if incrCmd := memCache.IncrBy("counter", 1, -1); incrCmd.Error() != ErrNotInteger {
+		t.Fatal(incrCmd.Error())
+	}
+
+	memCache.Set("string", "abc", -1)
+	if incrCmd := memCache.IncrByFloat("string", 1, -1); incrCmd.Error() != ErrNotFloat {
+		t.Fatal(incrCmd.Error())
+	}
+
+	memCache.Set("max", int64(math.MaxInt64), -1)
+	if incrCmd := memCache.IncrBy("max", 1, -1); incrCmd.Error() != ErrNotInteger {
+		t.Fatal(incrCmd.Error())
+	}
+}
+
+func TestMemCache_SetNX(t *testing.T) {
+	memCache := NewMemCache(NewDefaultOptions())
+
+	// 并发抢占，只有一个成功
+	var (
+		wg      sync.WaitGroup
+		claimed int32
+	)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if memCache.SetNX("job", i, time.Minute).Val() {
+				atomic.AddInt32(&claimed, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if claimed != 1 {
+		t.Fatal(claimed)
+	}
+
+	if setCmd := memCache.SetXX("not_exists", 1, -1); setCmd.Val() || memCache.Get("not_exists").Exists() {
+		t.Fatal("SetXX should not write")
+	}
+	if setCmd := memCache.SetXX("job", "done", -1); !setCmd.Val() || memCache.Get("job").ValString() != "done" {
+		t.Fatal("SetXX should write")
+	}
+
+	getCmd := memCache.GetSet("job", "again", -1)
+	if !getCmd.Exists() || getCmd.ValString() != "done" || memCache.Get("job").ValString() != "again" {
+		t.Fatal(getCmd.ValString())
+	}
+	if getCmd := memCache.GetSet("new", 1, -1); getCmd.Exists() {
+		t.Fatal("GetSet new key should not exists")
+	}
+
+	memCache.Set("version", 1, -1)
+	var swapped int32
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if memCache.CompareAndSwap("version", 1, 2, -1).Val() {
+				atomic.AddInt32(&swapped, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if swapped != 1 {
+		t.Fatal(swapped)
+	}
+	// 与编码后的值比较
+	if !memCache.CompareAndSwap("version", float64(2), "3", -1).Val() {
+		t.Fatal("2 should equal float64(2)")
+	}
+	if memCache.CompareAndSwap("not_exists", nil, 1, -1).Val() {
+		t.Fatal("CompareAndSwap should not write not exists key")
+	}
+}
+
+func TestMemCache_MGet(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Shards = 4
+	memCache := NewMemCache(opt)
+	values := map[string]interface{}{"k1": 1, "k2": "2", "k3": 3.0}
+	if setCmd := memCache.MSet(values, time.Minute); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	memCache.Set("expired", 1, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	cmds := memCache.MGet("k1", "not_exists", "k2", "expired", "k3").Val()
+	if len(cmds) != 5 {
+		t.Fatal(len(cmds))
+	}
+	if !cmds[0].Exists() || cmds[0].ValString() != "1" || cmds[0].TTL() <= 0 {
+		t.Fatal(cmds[0])
+	}
+	if cmds[1].Exists() || cmds[3].Exists() {
+		t.Fatal("should not exists")
+	}
+	if cmds[2].ValString() != "2" || cmds[4].ValString() != "3" {
+		t.Fatal(cmds[2].ValString(), cmds[4].ValString())
+	}
+
+	if delCmd := memCache.MDelete("k1", "k2", "not_exists"); delCmd.Val() != 2 {
+		t.Fatal(delCmd.Val())
+	}
+	if keys := memCache.Keys("k").Val(); len(keys) != 1 || keys[0] != "k3" {
+		t.Fatal(keys)
+	}
+}
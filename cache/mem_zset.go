@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"math"
+)
+
+// ZAdd 返回新增的 member 数量，已存在的 member 更新 score
+func (mem *MemCache) ZAdd(key string, members ...Z) *IntCmd {
+	for _, m := range members {
+		if math.IsNaN(m.Score) {
+			return &IntCmd{baseCmd: baseCmd{err: ErrNotFloat}}
+		}
+	}
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	defer mem.unlock(sh)
+	val, err := mem.collectionLocked(sh, key, KindZSet, newZSet)
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	z := val.Value.(*zset)
+
+	// 同一个 member 多次出现时以最后一次为准
+	scores := make(map[string]float64, len(members))
+	for _, m := range members {
+		scores[m.Member] = m.Score
+	}
+	addSize := int64(0)
+	for member, score := range scores {
+		addSize += mem.sizeOf(member, score)
+		if old, ok := z.dict[member]; ok {
+			addSize -= mem.sizeOf(member, old)
+		}
+	}
+	if err := mem.growLocked(sh, key, addSize); err != nil {
+		mem.saveCollectionLocked(sh, key, val, z.len(), "", nil)
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	n := int64(0)
+	for _, m := range members {
+		if z.add(m.Member, m.Score) {
+			n++
+		}
+	}
+	val.Size += addSize
+	mem.saveCollectionLocked(sh, key, val, z.len(), aofZAdd, members)
+	return &IntCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: n}
+}
+
+// ZIncrBy member 不存在时从 0 开始
+func (mem *MemCache) ZIncrBy(key string, increment float64, member string) *FloatCmd {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	defer mem.unlock(sh)
+	val, err := mem.collectionLocked(sh, key, KindZSet, newZSet)
+	if err != nil {
+		return &FloatCmd{baseCmd: baseCmd{err: err}}
+	}
+	z := val.Value.(*zset)
+	old, exists := z.dict[member]
+	score := old + increment
+	if math.IsNaN(score) {
+		mem.saveCollectionLocked(sh, key, val, z.len(), "", nil)
+		return &FloatCmd{baseCmd: baseCmd{err: ErrNotFloat}}
+	}
+
+	addSize := mem.sizeOf(member, score)
+	if exists {
+		addSize -= mem.sizeOf(member, old)
+	}
+	if err := mem.growLocked(sh, key, addSize); err != nil {
+		mem.saveCollectionLocked(sh, key, val, z.len(), "", nil)
+		return &FloatCmd{baseCmd: baseCmd{err: err}}
+	}
+	z.add(member, score)
+	val.Size += addSize
+	// 记录增加后的 score，重放结果与 ZAdd 相同
+	mem.saveCollectionLocked(sh, key, val, z.len(), aofZAdd, []Z{{Score: score, Member: member}})
+	return &FloatCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: score}
+}
+
+// ZRem 返回删除的 member 数量
+func (mem *MemCache) ZRem(key string, members ...string) *IntCmd {
+	sh := mem.shard(key)
+	sh.rwMutex.Lock()
+	defer mem.unlock(sh)
+	val, ok, err := readCollectionLocked(sh, key, KindZSet)
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	if !ok {
+		return &IntCmd{}
+	}
+	z := val.Value.(*zset)
+	removed := make([]string, 0, len(members))
+	for _, member := range members {
+		score, ok := z.dict[member]
+		if !ok {
+			continue
+		}
+		z.remove(member)
+		size := mem.sizeOf(member, score)
+		mem.reserve(sh, -size, 0)
+		val.Size -= size
+		removed = append(removed, member)
+	}
+	n := int64(len(removed))
+	if n == 0 {
+		mem.saveCollectionLocked(sh, key, val, z.len(), "", nil)
+	} else {
+		mem.saveCollectionLocked(sh, key, val, z.len(), aofZRem, removed)
+	}
+	return &IntCmd{value: n}
+}
+
+// readZSet 在分片读锁内读取有序集合
+func (mem *MemCache) readZSet(key string, fn func(z *zset)) (WrapValue, bool, error) {
+	sh := mem.shard(key)
+	sh.rwMutex.RLock()
+	defer sh.rwMutex.RUnlock()
+	val, ok, err := readCollectionLocked(sh, key, KindZSet)
+	if err != nil || !ok {
+		return val, ok, err
+	}
+	fn(val.Value.(*zset))
+	return val, true, nil
+}
+
+// ZRange 按排名 [start, stop] 返回，从 0 开始，负数表示从尾部开始计算
+func (mem *MemCache) ZRange(key string, start, stop int64) *ZSliceCmd {
+	values := make([]Z, 0)
+	val, ok, err := mem.readZSet(key, func(z *zset) {
+		values = z.rangeByRank(start, stop)
+	})
+	if err != nil {
+		return &ZSliceCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &ZSliceCmd{baseCmd: baseCmd{exists: ok, ttl: val.TTL()}, value: values}
+}
+
+// ZRangeByScore 返回 score 在 [min, max] 内的成员，可以使用 math.Inf 表示无穷
+func (mem *MemCache) ZRangeByScore(key string, min, max float64) *ZSliceCmd {
+	values := make([]Z, 0)
+	val, ok, err := mem.readZSet(key, func(z *zset) {
+		values = z.rangeByScore(min, max)
+	})
+	if err != nil {
+		return &ZSliceCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &ZSliceCmd{baseCmd: baseCmd{exists: ok, ttl: val.TTL()}, value: values}
+}
+
+// ZRank 从 0 开始的排名，member 不存在时 Exists 为 false
+func (mem *MemCache) ZRank(key, member string) *IntCmd {
+	var (
+		rank   int
+		exists bool
+	)
+	val, _, err := mem.readZSet(key, func(z *zset) {
+		rank, exists = z.rank(member)
+	})
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	if !exists {
+		return &IntCmd{}
+	}
+	return &IntCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: int64(rank)}
+}
+
+func (mem *MemCache) ZScore(key, member string) *FloatCmd {
+	var (
+		score  float64
+		exists bool
+	)
+	val, _, err := mem.readZSet(key, func(z *zset) {
+		score, exists = z.dict[member]
+	})
+	if err != nil {
+		return &FloatCmd{baseCmd: baseCmd{err: err}}
+	}
+	if !exists {
+		return &FloatCmd{}
+	}
+	return &FloatCmd{baseCmd: baseCmd{exists: true, ttl: val.TTL()}, value: score}
+}
+
+func (mem *MemCache) ZCard(key string) *IntCmd {
+	n := 0
+	val, ok, err := mem.readZSet(key, func(z *zset) {
+		n = z.len()
+	})
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{baseCmd: baseCmd{exists: ok, ttl: val.TTL()}, value: int64(n)}
+}
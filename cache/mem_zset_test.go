@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"testing"
+)
+
+var (
+	_ SortedSetCache = (*MemCache)(nil)
+	_ SortedSetCache = (*RedisCache)(nil)
+)
+
+func TestMemCache_ZSet(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Filename = "./cache_zset.bak"
+	defer os.Remove(opt.Filename)
+	memCache := NewMemCache(opt)
+
+	addCmd := memCache.ZAdd("board", Z{Score: 10, Member: "tom"}, Z{Score: 30, Member: "jerry"}, Z{Score: 20, Member: "spike"})
+	if addCmd.Error() != nil || addCmd.Val() != 3 {
+		t.Fatal(addCmd.Val(), addCmd.Error())
+	}
+	if addCmd := memCache.ZAdd("board", Z{Score: 40, Member: "tom"}); addCmd.Val() != 0 {
+		t.Fatal("tom should not be new member")
+	}
+	if incrCmd := memCache.ZIncrBy("board", 5, "spike"); incrCmd.Val() != 25 {
+		t.Fatal(incrCmd.Val())
+	}
+
+	values := memCache.ZRange("board", 0, -1).Val()
+	if len(values) != 3 || values[0].Member != "spike" || values[2].Member != "tom" || values[2].Score != 40 {
+		t.Fatal(values)
+	}
+	if values := memCache.ZRange("board", -1, -1).Val(); len(values) != 1 || values[0].Member != "tom" {
+		t.Fatal(values)
+	}
+	if values := memCache.ZRangeByScore("board", 25, 30).Val(); len(values) != 2 || values[1].Member != "jerry" {
+		t.Fatal(values)
+	}
+	if values := memCache.ZRangeByScore("board", math.Inf(-1), math.Inf(1)).Val(); len(values) != 3 {
+		t.Fatal(values)
+	}
+	if rankCmd := memCache.ZRank("board", "jerry"); !rankCmd.Exists() || rankCmd.Val() != 1 {
+		t.Fatal(rankCmd.Val())
+	}
+	if rankCmd := memCache.ZRank("board", "nobody"); rankCmd.Exists() {
+		t.Fatal("nobody should not exists")
+	}
+
+	if err := memCache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	memCache = NewMemCache(opt)
+	if scoreCmd := memCache.ZScore("board", "tom"); scoreCmd.Val() != 40 {
+		t.Fatal(scoreCmd.Val())
+	}
+	if rankCmd := memCache.ZRank("board", "tom"); rankCmd.Val() != 2 {
+		t.Fatal(rankCmd.Val())
+	}
+	if remCmd := memCache.ZRem("board", "tom", "nobody"); remCmd.Val() != 1 {
+		t.Fatal(remCmd.Val())
+	}
+	if n := memCache.ZCard("board").Val(); n != 2 {
+		t.Fatal(n)
+	}
+}
+
+func TestZSet_Random(t *testing.T) {
+	z := newZSet().(*zset)
+	scores := make(map[string]float64)
+	for i := 0; i < 2000; i++ {
+		member := fmt.Sprintf("m%d", rand.Intn(500))
+		if rand.Intn(4) == 0 {
+			z.remove(member)
+			delete(scores, member)
+			continue
+		}
+		score := float64(rand.Intn(100))
+		z.add(member, score)
+		scores[member] = score
+	}
+
+	expect := make([]Z, 0, len(scores))
+	for member, score := range scores {
+		expect = append(expect, Z{Score: score, Member: member})
+	}
+	sort.Slice(expect, func(i, j int) bool {
+		if expect[i].Score != expect[j].Score {
+			return expect[i].Score < expect[j].Score
+		}
+		return expect[i].Member < expect[j].Member
+	})
+
+	values := z.rangeByRank(0, -1)
+	if len(values) != len(expect) {
+		t.Fatal(len(values), len(expect))
+	}
+	for i := range expect {
+		if values[i] != expect[i] {
+			t.Fatal(i, values[i], expect[i])
+		}
+		if rank, ok := z.rank(expect[i].Member); !ok || rank != i {
+			t.Fatal(expect[i].Member, rank, i)
+		}
+		if values := z.rangeByRank(int64(i), int64(i)); values[0] != expect[i] {
+			t.Fatal(i, values[0], expect[i])
+		}
+	}
+
+	count := 0
+	for _, v := range expect {
+		if v.Score >= 20 && v.Score <= 30 {
+			count++
+		}
+	}
+	if values := z.rangeByScore(20, 30); len(values) != count {
+		t.Fatal(len(values), count)
+	}
+}
+
+func TestMemCache_ZSetDrainSize(t *testing.T) {
+	opt := NewDefaultOptions()
+	// key 占 1，每个 member 加 score 占 9
+	opt.Size = 19
+	memCache := NewMemCache(opt)
+	// 删除所有 member 后 key 被删除，容量全部释放
+	for i := 0; i < 3; i++ {
+		memCache.ZAdd("z", Z{Score: 1, Member: "a"}, Z{Score: 2, Member: "b"})
+		memCache.ZRem("z", "a", "b")
+		if stats := memCache.Stats().Val(); stats.Bytes != 0 || stats.Keys != 0 {
+			t.Fatal(stats)
+		}
+	}
+	memCache.ZAdd("z", Z{Score: 1, Member: "a"}, Z{Score: 2, Member: "b"})
+	if addCmd := memCache.ZAdd("z", Z{Score: 3, Member: "c"}); addCmd.Error() != ErrKeysOverCapacity {
+		t.Fatal("should over size")
+	}
+}
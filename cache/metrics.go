@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+/*
+	Prometheus 文本格式的统计
+Notice:
+	1. 每次请求时读取 Stats，RedisCache 会执行一次 INFO 和 DBSIZE
+	2. 多个 cache 可以共用一个 Handler，通过 cache 标签区分
+	3. 读取 Stats 出错时返回 500，不输出部分数据
+*/
+
+// MetricsNamespace 指标名称的前缀
+const MetricsNamespace = "gokit_cache"
+
+type metric struct {
+	name  string
+	help  string
+	typ   string
+	value func(s Stats) int64
+}
+
+var metrics = []metric{
+	{"hits_total", "Number of cache hits.", "counter", func(s Stats) int64 { return s.Hits }},
+	{"misses_total", "Number of cache misses.", "counter", func(s Stats) int64 { return s.Misses }},
+	{"sets_total", "Number of cache writes.", "counter", func(s Stats) int64 { return s.Sets }},
+	{"deletes_total", "Number of keys deleted.", "counter", func(s Stats) int64 { return s.Deletes }},
+	{"expirations_total", "Number of expired keys removed.", "counter", func(s Stats) int64 { return s.Expirations }},
+	{"evictions_total", "Number of keys evicted.", "counter", func(s Stats) int64 { return s.Evictions }},
+	{"keys", "Number of keys.", "gauge", func(s Stats) int64 { return s.Keys }},
+	{"bytes", "Estimated size in bytes.", "gauge", func(s Stats) int64 { return s.Bytes }},
+}
+
+// MetricsHandler 输出 Prometheus 文本格式的统计
+type MetricsHandler struct {
+	names  []string
+	caches []StatsCache
+}
+
+// NewMetricsHandler name 为 cache 标签的值
+func NewMetricsHandler(name string, c StatsCache) *MetricsHandler {
+	return new(MetricsHandler).Register(name, c)
+}
+
+// Register 添加一个 cache，不能与 ServeHTTP 同时调用
+func (h *MetricsHandler) Register(name string, c StatsCache) *MetricsHandler {
+	h.names = append(h.names, name)
+	h.caches = append(h.caches, c)
+	return h
+}
+
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stats := make([]Stats, len(h.caches))
+	for i, c := range h.caches {
+		statsCmd := c.Stats()
+		if statsCmd.Error() != nil {
+			http.Error(w, fmt.Sprintf("cache %s: %s", h.names[i], statsCmd.Error().Error()), http.StatusInternalServerError)
+			return
+		}
+		stats[i] = statsCmd.Val()
+	}
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		name := MetricsNamespace + "_" + m.name
+		fmt.Fprintf(&buf, "# HELP %s %s\n", name, m.help)
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", name, m.typ)
+		for i, s := range stats {
+			fmt.Fprintf(&buf, "%s{cache=\"%s\"} %d\n", name, escapeLabel(h.names[i]), m.value(s))
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel 按 Prometheus 文本格式转义标签值
+func escapeLabel(value string) string {
+	return labelReplacer.Replace(value)
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"strings"
+	"time"
+)
+
+/*
+	命名空间，多个模块共用一个 Cache 时避免 key 冲突
+Notice:
+	1. 所有 key 自动加上 prefix，Keys 和 Scan 返回的 key 去掉 prefix
+	2. FlushAll 通过 Scan 分批删除命名空间内的 key，不是原子操作，期间写入的 key 可能不会被删除
+	3. 嵌套的命名空间合并为一个，Namespace(Namespace(c, "a:"), "b:") 等同于 Namespace(c, "a:b:")
+	4. Save 保存底层的整个 Cache，Close 不关闭底层的 Cache，由创建者关闭
+*/
+
+// namespaceFlushCount FlushAll 每次 Scan 和删除的数量
+const namespaceFlushCount = 1000
+
+type namespaceCache struct {
+	c      Cache
+	prefix string
+}
+
+// Namespace 返回 key 带有 prefix 的 Cache，prefix 为空时直接返回 c
+func Namespace(c Cache, prefix string) Cache {
+	if prefix == "" {
+		return c
+	}
+	if ns, ok := c.(*namespaceCache); ok {
+		return &namespaceCache{c: ns.c, prefix: ns.prefix + prefix}
+	}
+	return &namespaceCache{c: c, prefix: prefix}
+}
+
+func (ns *namespaceCache) key(key string) string {
+	return ns.prefix + key
+}
+
+func (ns *namespaceCache) keys(keys []string) []string {
+	result := make([]string, len(keys))
+	for i, key := range keys {
+		result[i] = ns.prefix + key
+	}
+	return result
+}
+
+// trim 去掉 prefix，直接修改 keys
+func (ns *namespaceCache) trim(keys []string) []string {
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, ns.prefix)
+	}
+	return keys
+}
+
+func (ns *namespaceCache) Get(key string) *Cmd {
+	return ns.c.Get(ns.key(key))
+}
+
+func (ns *namespaceCache) Set(key string, value interface{}, ttl time.Duration) *StatusCmd {
+	return ns.c.Set(ns.key(key), value, ttl)
+}
+
+func (ns *namespaceCache) Keys(prefix string) *SliceStringCmd {
+	cmd := ns.c.Keys(ns.key(prefix))
+	if cmd.Error() != nil {
+		return cmd
+	}
+	return &SliceStringCmd{value: ns.trim(cmd.Val())}
+}
+
+// Scan pattern 为空时返回命名空间内所有的 key
+func (ns *namespaceCache) Scan(cursor uint64, pattern string, count int64) *ScanCmd {
+	if pattern == "" {
+		pattern = "*"
+	}
+	cmd := ns.c.Scan(cursor, escapeGlob(ns.prefix)+pattern, count)
+	if cmd.Error() != nil {
+		return cmd
+	}
+	return &ScanCmd{value: ns.trim(cmd.Val()), cursor: cmd.Cursor()}
+}
+
+func (ns *namespaceCache) Delete(key string) *StatusCmd {
+	return ns.c.Delete(ns.key(key))
+}
+
+func (ns *namespaceCache) Expire(key string, ttl time.Duration) *BoolCmd {
+	return ns.c.Expire(ns.key(key), ttl)
+}
+
+func (ns *namespaceCache) ExpireAt(key string, tm time.Time) *BoolCmd {
+	return ns.c.ExpireAt(ns.key(key), tm)
+}
+
+func (ns *namespaceCache) Persist(key string) *BoolCmd {
+	return ns.c.Persist(ns.key(key))
+}
+
+func (ns *namespaceCache) Touch(key string, ttl time.Duration) *BoolCmd {
+	return ns.c.Touch(ns.key(key), ttl)
+}
+
+func (ns *namespaceCache) IncrBy(key string, value int64, ttl time.Duration) *IntCmd {
+	return ns.c.IncrBy(ns.key(key), value, ttl)
+}
+
+func (ns *namespaceCache) DecrBy(key string, value int64, ttl time.Duration) *IntCmd {
+	return ns.c.DecrBy(ns.key(key), value, ttl)
+}
+
+func (ns *namespaceCache) IncrByFloat(key string, value float64, ttl time.Duration) *FloatCmd {
+	return ns.c.IncrByFloat(ns.key(key), value, ttl)
+}
+
+func (ns *namespaceCache) SetNX(key string, value interface{}, ttl time.Duration) *BoolCmd {
+	return ns.c.SetNX(ns.key(key), value, ttl)
+}
+
+func (ns *namespaceCache) SetXX(key string, value interface{}, ttl time.Duration) *BoolCmd {
+	return ns.c.SetXX(ns.key(key), value, ttl)
+}
+
+func (ns *namespaceCache) GetSet(key string, value interface{}, ttl time.Duration) *Cmd {
+	return ns.c.GetSet(ns.key(key), value, ttl)
+}
+
+func (ns *namespaceCache) CompareAndSwap(key string, old, new interface{}, ttl time.Duration) *BoolCmd {
+	return ns.c.CompareAndSwap(ns.key(key), old, new, ttl)
+}
+
+func (ns *namespaceCache) MGet(keys ...string) *MultiCmd {
+	return ns.c.MGet(ns.keys(keys)...)
+}
+
+func (ns *namespaceCache) MSet(values map[string]interface{}, ttl time.Duration) *StatusCmd {
+	prefixed := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		prefixed[ns.key(key)] = value
+	}
+	return ns.c.MSet(prefixed, ttl)
+}
+
+func (ns *namespaceCache) MDelete(keys ...string) *IntCmd {
+	return ns.c.MDelete(ns.keys(keys)...)
+}
+
+// FlushAll 只删除命名空间内的 key
+func (ns *namespaceCache) FlushAll() *StatusCmd {
+	pattern := escapeGlob(ns.prefix) + "*"
+	cursor := uint64(0)
+	for {
+		scanCmd := ns.c.Scan(cursor, pattern, namespaceFlushCount)
+		if scanCmd.Error() != nil {
+			return &StatusCmd{baseCmd: baseCmd{err: scanCmd.Error()}}
+		}
+		if keys := scanCmd.Val(); len(keys) > 0 {
+			if delCmd := ns.c.MDelete(keys...); delCmd.Error() != nil {
+				return &StatusCmd{baseCmd: baseCmd{err: delCmd.Error()}}
+			}
+		}
+		if cursor = scanCmd.Cursor(); cursor == 0 {
+			return &StatusCmd{value: StatusOK}
+		}
+	}
+}
+
+// Save 保存底层的整个 Cache
+func (ns *namespaceCache) Save() *StatusCmd {
+	return ns.c.Save()
+}
+
+// Close 不关闭底层的 Cache
+func (ns *namespaceCache) Close() error {
+	return nil
+}
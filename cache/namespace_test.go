@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func testNamespace(t *testing.T, c Cache) {
+	users := Namespace(c, "user:")
+	orders := Namespace(c, "order:")
+	c.Set("global", 1, -1)
+	users.Set("1", "alice", -1)
+	users.Set("2", "bob", -1)
+	orders.Set("1", "book", -1)
+
+	if getCmd := c.Get("user:1"); getCmd.ValString() != "alice" {
+		t.Fatal(getCmd.ValString())
+	}
+	if getCmd := orders.Get("1"); getCmd.ValString() != "book" {
+		t.Fatal(getCmd.ValString())
+	}
+	keys := users.Keys("").Val()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "1" || keys[1] != "2" {
+		t.Fatal(keys)
+	}
+	if keys := scanAll(t, users, "[1]", func() {}); len(keys) != 1 || keys[0] != "1" {
+		t.Fatal(keys)
+	}
+
+	users.MSet(map[string]interface{}{"3": "carol", "4": "dave"}, -1)
+	multiCmd := users.MGet("3", "4", "5")
+	if values := multiCmd.Val(); values[0].ValString() != "carol" || values[1].ValString() != "dave" || values[2].Exists() {
+		t.Fatal(values)
+	}
+	if n := users.MDelete("3", "5").Val(); n != 1 {
+		t.Fatal(n)
+	}
+	if n := users.IncrBy("n", 2, 0).Val(); n != 2 || c.Get("user:n").ValString() != "2" {
+		t.Fatal(n)
+	}
+	if !users.Expire("1", time.Hour).Val() {
+		t.Fatal("expire user:1")
+	}
+
+	// 嵌套的命名空间
+	admins := Namespace(users, "admin:")
+	admins.Set("1", "root", -1)
+	if getCmd := c.Get("user:admin:1"); getCmd.ValString() != "root" {
+		t.Fatal(getCmd.ValString())
+	}
+	if keys := users.Keys("admin:").Val(); len(keys) != 1 || keys[0] != "admin:1" {
+		t.Fatal(keys)
+	}
+
+	// FlushAll 只删除命名空间内的 key
+	if err := users.FlushAll().Error(); err != nil {
+		t.Fatal(err)
+	}
+	keys = c.Keys("").Val()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "global" || keys[1] != "order:1" {
+		t.Fatal(keys)
+	}
+	if err := users.Close(); err != nil || !c.Get("global").Exists() {
+		t.Fatal("close should not close the underlying cache")
+	}
+}
+
+func TestNamespace_MemCache(t *testing.T) {
+	testNamespace(t, NewMemCache())
+}
+
+func TestNamespace_RedisCache(t *testing.T) {
+	redisCache, s := newTestRedisCache(t)
+	defer s.Close()
+	testNamespace(t, redisCache)
+}
+
+func TestNamespace_FlushAll(t *testing.T) {
+	memCache := NewMemCache()
+	for i := 0; i < 3000; i++ {
+		memCache.Set(fmt.Sprintf("a*:%d", i), i, -1)
+	}
+	memCache.Set("ab:1", 1, -1)
+	if Namespace(memCache, "") != Cache(memCache) {
+		t.Fatal("empty prefix should return the cache")
+	}
+	// prefix 中的通配符按普通字符处理
+	if err := Namespace(memCache, "a*:").FlushAll().Error(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := memCache.Keys("").Val(); len(keys) != 1 || keys[0] != "ab:1" {
+		t.Fatal(keys)
+	}
+}
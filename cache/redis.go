@@ -1 +1,872 @@
 package cache
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+/*
+	Redis 实现cache
+Notice:
+	1. 与 MemCache 返回相同的 Cmd，可以只替换构造函数进行切换。
+	2. 非基础类型的值以 JSON 写入，读取时统一返回 string。不使用 Codec，保证 INCRBY 等命令和其他客户端可以直接读写。
+	3. 没有过期时间的 key，TTL 返回 -1。
+	4. 标签保存在 tagKeyPrefix 开头的 set 中，Set 覆盖 key 时不会移除 key 的标签，与 MemCache 一致。
+*/
+
+type RedisOptions struct {
+	Addr     string
+	Password string
+	DB       int
+}
+
+type RedisCache struct {
+	client *redis.Client
+}
+
+func NewRedisCache(opt RedisOptions) *RedisCache {
+	client := redis.NewClient(&redis.Options{
+		Network:  "tcp",
+		Addr:     opt.Addr,
+		Password: opt.Password,
+		DB:       opt.DB,
+	})
+	return NewRedisCacheWithClient(client)
+}
+
+// NewRedisCacheWithClient 复用已有的 redis 连接
+func NewRedisCacheWithClient(client *redis.Client) *RedisCache {
+	return &RedisCache{
+		client: client,
+	}
+}
+
+// Client 返回底层的 redis 连接
+func (rc *RedisCache) Client() *redis.Client {
+	return rc.client
+}
+
+func (rc *RedisCache) Get(key string) *Cmd {
+	return rc.GetContext(context.Background(), key)
+}
+
+func (rc *RedisCache) GetContext(ctx context.Context, key string) *Cmd {
+	client := rc.client.WithContext(ctx)
+	var (
+		getCmd *redis.StringCmd
+		ttlCmd *redis.DurationCmd
+	)
+	_, err := client.Pipelined(func(pipe redis.Pipeliner) error {
+		getCmd = pipe.Get(key)
+		ttlCmd = pipe.PTTL(key)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+
+	val, err := getCmd.Result()
+	if err == redis.Nil {
+		return &Cmd{}
+	}
+	if err != nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+	return &Cmd{baseCmd: baseCmd{exists: true, ttl: ttlCmd.Val()}, value: val}
+}
+
+// Set ttl <= 0 时不过期
+func (rc *RedisCache) Set(key string, value interface{}, ttl time.Duration) *StatusCmd {
+	return rc.SetContext(context.Background(), key, value, ttl)
+}
+
+func (rc *RedisCache) SetContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *StatusCmd {
+	client := rc.client.WithContext(ctx)
+	val, err := encodeRedisValue(value)
+	if err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	if err := client.Set(key, val, ttl).Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	if ttl == 0 {
+		ttl = -1
+	}
+	return &StatusCmd{baseCmd: baseCmd{exists: true, ttl: ttl}, value: StatusOK}
+}
+
+func (rc *RedisCache) Keys(prefix string) *SliceStringCmd {
+	return rc.KeysContext(context.Background(), prefix)
+}
+
+func (rc *RedisCache) KeysContext(ctx context.Context, prefix string) *SliceStringCmd {
+	client := rc.client.WithContext(ctx)
+	keys := make([]string, 0)
+	iter := client.Scan(0, escapeGlob(prefix)+"*", 1000).Iterator()
+	for iter.Next() {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return &SliceStringCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &SliceStringCmd{value: keys}
+}
+
+func (rc *RedisCache) Scan(cursor uint64, pattern string, count int64) *ScanCmd {
+	return rc.ScanContext(context.Background(), cursor, pattern, count)
+}
+
+func (rc *RedisCache) ScanContext(ctx context.Context, cursor uint64, pattern string, count int64) *ScanCmd {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	keys, cursor, err := rc.client.WithContext(ctx).Scan(cursor, pattern, count).Result()
+	if err != nil {
+		return &ScanCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &ScanCmd{value: keys, cursor: cursor}
+}
+
+func (rc *RedisCache) Delete(key string) *StatusCmd {
+	return rc.DeleteContext(context.Background(), key)
+}
+
+func (rc *RedisCache) DeleteContext(ctx context.Context, key string) *StatusCmd {
+	client := rc.client.WithContext(ctx)
+	if err := client.Del(key).Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &StatusCmd{value: StatusOK}
+}
+
+// incrScript key 不存在时才设置过期时间，返回 {新值, PTTL}
+var incrScript = redis.NewScript(`
+local exists = redis.call('EXISTS', KEYS[1])
+local val = redis.call(ARGV[1], KEYS[1], ARGV[2])
+if exists == 0 and tonumber(ARGV[3]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return {val, redis.call('PTTL', KEYS[1])}
+`)
+
+func (rc *RedisCache) Expire(key string, ttl time.Duration) *BoolCmd {
+	return rc.ExpireContext(context.Background(), key, ttl)
+}
+
+func (rc *RedisCache) ExpireContext(ctx context.Context, key string, ttl time.Duration) *BoolCmd {
+	ok, err := rc.client.WithContext(ctx).PExpire(key, ttl).Result()
+	return expireResult(ok, ttl, err)
+}
+
+func (rc *RedisCache) ExpireAt(key string, tm time.Time) *BoolCmd {
+	return rc.ExpireAtContext(context.Background(), key, tm)
+}
+
+func (rc *RedisCache) ExpireAtContext(ctx context.Context, key string, tm time.Time) *BoolCmd {
+	ok, err := rc.client.WithContext(ctx).PExpireAt(key, tm).Result()
+	return expireResult(ok, time.Until(tm), err)
+}
+
+func (rc *RedisCache) Persist(key string) *BoolCmd {
+	return rc.PersistContext(context.Background(), key)
+}
+
+func (rc *RedisCache) PersistContext(ctx context.Context, key string) *BoolCmd {
+	ok, err := rc.client.WithContext(ctx).Persist(key).Result()
+	if err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	if !ok {
+		return &BoolCmd{}
+	}
+	return &BoolCmd{baseCmd: baseCmd{exists: true, ttl: -1}, value: true}
+}
+
+func (rc *RedisCache) Touch(key string, ttl time.Duration) *BoolCmd {
+	return rc.TouchContext(context.Background(), key, ttl)
+}
+
+// TouchContext TOUCH 更新访问时间后 PEXPIRE
+func (rc *RedisCache) TouchContext(ctx context.Context, key string, ttl time.Duration) *BoolCmd {
+	var expireCmd *redis.BoolCmd
+	_, err := rc.client.WithContext(ctx).Pipelined(func(pipe redis.Pipeliner) error {
+		pipe.Touch(key)
+		expireCmd = pipe.PExpire(key, ttl)
+		return nil
+	})
+	if err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return expireResult(expireCmd.Val(), ttl, nil)
+}
+
+// expireResult 设置的过期时间已经过去时 key 被删除
+func expireResult(ok bool, ttl time.Duration, err error) *BoolCmd {
+	if err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	if !ok || ttl <= 0 {
+		return &BoolCmd{value: ok}
+	}
+	return &BoolCmd{baseCmd: baseCmd{exists: true, ttl: ttl}, value: true}
+}
+
+func incr(client *redis.Client, command, key string, value interface{}, ttl time.Duration) ([]interface{}, error) {
+	res, err := incrScript.Run(client, []string{key}, command, value, ttl.Milliseconds()).Result()
+	if err != nil {
+		return nil, err
+	}
+	return res.([]interface{}), nil
+}
+
+func (rc *RedisCache) IncrBy(key string, value int64, ttl time.Duration) *IntCmd {
+	return rc.IncrByContext(context.Background(), key, value, ttl)
+}
+
+func (rc *RedisCache) IncrByContext(ctx context.Context, key string, value int64, ttl time.Duration) *IntCmd {
+	client := rc.client.WithContext(ctx)
+	res, err := incr(client, "INCRBY", key, value, ttl)
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{baseCmd: baseCmd{exists: true, ttl: pttl(res[1])}, value: res[0].(int64)}
+}
+
+func (rc *RedisCache) DecrBy(key string, value int64, ttl time.Duration) *IntCmd {
+	return rc.DecrByContext(context.Background(), key, value, ttl)
+}
+
+func (rc *RedisCache) DecrByContext(ctx context.Context, key string, value int64, ttl time.Duration) *IntCmd {
+	client := rc.client.WithContext(ctx)
+	res, err := incr(client, "DECRBY", key, value, ttl)
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{baseCmd: baseCmd{exists: true, ttl: pttl(res[1])}, value: res[0].(int64)}
+}
+
+func (rc *RedisCache) IncrByFloat(key string, value float64, ttl time.Duration) *FloatCmd {
+	return rc.IncrByFloatContext(context.Background(), key, value, ttl)
+}
+
+func (rc *RedisCache) IncrByFloatContext(ctx context.Context, key string, value float64, ttl time.Duration) *FloatCmd {
+	client := rc.client.WithContext(ctx)
+	res, err := incr(client, "INCRBYFLOAT", key, value, ttl)
+	if err != nil {
+		return &FloatCmd{baseCmd: baseCmd{err: err}}
+	}
+	f, err := toFloat64(res[0])
+	if err != nil {
+		return &FloatCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &FloatCmd{baseCmd: baseCmd{exists: true, ttl: pttl(res[1])}, value: f}
+}
+
+// pttl 转换脚本返回的 PTTL 毫秒数，与 redis.DurationCmd 一致，-1 表示不过期
+func pttl(v interface{}) time.Duration {
+	n, _ := v.(int64)
+	if n < 0 {
+		return time.Duration(n)
+	}
+	return time.Duration(n) * time.Millisecond
+}
+
+func (rc *RedisCache) SetNX(key string, value interface{}, ttl time.Duration) *BoolCmd {
+	return rc.SetNXContext(context.Background(), key, value, ttl)
+}
+
+func (rc *RedisCache) SetNXContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *BoolCmd {
+	client := rc.client.WithContext(ctx)
+	return rc.setIf(client.SetNX, key, value, ttl)
+}
+
+func (rc *RedisCache) SetXX(key string, value interface{}, ttl time.Duration) *BoolCmd {
+	return rc.SetXXContext(context.Background(), key, value, ttl)
+}
+
+func (rc *RedisCache) SetXXContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *BoolCmd {
+	client := rc.client.WithContext(ctx)
+	return rc.setIf(client.SetXX, key, value, ttl)
+}
+
+func (rc *RedisCache) setIf(fn func(string, interface{}, time.Duration) *redis.BoolCmd, key string, value interface{}, ttl time.Duration) *BoolCmd {
+	val, err := encodeRedisValue(value)
+	if err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	ok, err := fn(key, val, ttl).Result()
+	if err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	if !ok {
+		return &BoolCmd{}
+	}
+	if ttl == 0 {
+		ttl = -1
+	}
+	return &BoolCmd{baseCmd: baseCmd{exists: true, ttl: ttl}, value: true}
+}
+
+// getSetScript 写入新值和过期时间，返回 {旧值, 旧值的 PTTL}
+var getSetScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+local old = redis.call('GET', KEYS[1])
+if tonumber(ARGV[2]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return {old, ttl}
+`)
+
+func (rc *RedisCache) GetSet(key string, value interface{}, ttl time.Duration) *Cmd {
+	return rc.GetSetContext(context.Background(), key, value, ttl)
+}
+
+func (rc *RedisCache) GetSetContext(ctx context.Context, key string, value interface{}, ttl time.Duration) *Cmd {
+	client := rc.client.WithContext(ctx)
+	val, err := encodeRedisValue(value)
+	if err != nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+	res, err := getSetScript.Run(client, []string{key}, val, ttl.Milliseconds()).Result()
+	if err != nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+	values := res.([]interface{})
+	if values[0] == nil {
+		return &Cmd{}
+	}
+	return &Cmd{baseCmd: baseCmd{exists: true, ttl: pttl(values[1])}, value: values[0]}
+}
+
+// casScript 当前值等于 ARGV[1] 时写入 ARGV[2]
+var casScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+func (rc *RedisCache) CompareAndSwap(key string, old, new interface{}, ttl time.Duration) *BoolCmd {
+	return rc.CompareAndSwapContext(context.Background(), key, old, new, ttl)
+}
+
+func (rc *RedisCache) CompareAndSwapContext(ctx context.Context, key string, old, new interface{}, ttl time.Duration) *BoolCmd {
+	client := rc.client.WithContext(ctx)
+	oldVal, err := encodeRedisValue(old)
+	if err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	newVal, err := encodeRedisValue(new)
+	if err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	n, err := casScript.Run(client, []string{key}, oldVal, newVal, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	if n == 0 {
+		return &BoolCmd{}
+	}
+	if ttl <= 0 {
+		ttl = -1
+	}
+	return &BoolCmd{baseCmd: baseCmd{exists: true, ttl: ttl}, value: true}
+}
+
+// MGet 在一次请求中执行 MGET 和每个 key 的 PTTL
+func (rc *RedisCache) MGet(keys ...string) *MultiCmd {
+	return rc.MGetContext(context.Background(), keys...)
+}
+
+func (rc *RedisCache) MGetContext(ctx context.Context, keys ...string) *MultiCmd {
+	client := rc.client.WithContext(ctx)
+	if len(keys) == 0 {
+		return &MultiCmd{value: []*Cmd{}}
+	}
+	var (
+		getCmd  *redis.SliceCmd
+		ttlCmds = make([]*redis.DurationCmd, len(keys))
+	)
+	_, err := client.Pipelined(func(pipe redis.Pipeliner) error {
+		getCmd = pipe.MGet(keys...)
+		for i, key := range keys {
+			ttlCmds[i] = pipe.PTTL(key)
+		}
+		return nil
+	})
+	if err != nil {
+		return &MultiCmd{baseCmd: baseCmd{err: err}}
+	}
+
+	cmds := make([]*Cmd, len(keys))
+	for i, val := range getCmd.Val() {
+		if val == nil {
+			cmds[i] = &Cmd{}
+			continue
+		}
+		cmds[i] = &Cmd{baseCmd: baseCmd{exists: true, ttl: ttlCmds[i].Val()}, value: val}
+	}
+	return &MultiCmd{value: cmds}
+}
+
+// MSet MSET 不支持过期时间，使用事务批量 SET
+func (rc *RedisCache) MSet(values map[string]interface{}, ttl time.Duration) *StatusCmd {
+	return rc.MSetContext(context.Background(), values, ttl)
+}
+
+func (rc *RedisCache) MSetContext(ctx context.Context, values map[string]interface{}, ttl time.Duration) *StatusCmd {
+	client := rc.client.WithContext(ctx)
+	if ttl < 0 {
+		ttl = 0
+	}
+	_, err := client.TxPipelined(func(pipe redis.Pipeliner) error {
+		for key, value := range values {
+			val, err := encodeRedisValue(value)
+			if err != nil {
+				return err
+			}
+			pipe.Set(key, val, ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &StatusCmd{value: StatusOK}
+}
+
+func (rc *RedisCache) MDelete(keys ...string) *IntCmd {
+	return rc.MDeleteContext(context.Background(), keys...)
+}
+
+func (rc *RedisCache) MDeleteContext(ctx context.Context, keys ...string) *IntCmd {
+	client := rc.client.WithContext(ctx)
+	if len(keys) == 0 {
+		return &IntCmd{}
+	}
+	n, err := client.Del(keys...).Result()
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{value: n}
+}
+
+func (rc *RedisCache) HSet(key, field string, value interface{}) *BoolCmd {
+	val, err := encodeRedisValue(value)
+	if err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	var (
+		setCmd *redis.IntCmd
+		ttlCmd *redis.DurationCmd
+	)
+	_, err = rc.client.Pipelined(func(pipe redis.Pipeliner) error {
+		setCmd = pipe.HSet(key, field, val)
+		ttlCmd = pipe.PTTL(key)
+		return nil
+	})
+	if err != nil {
+		return &BoolCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &BoolCmd{baseCmd: baseCmd{exists: true, ttl: ttlCmd.Val()}, value: setCmd.Val() == 1}
+}
+
+func (rc *RedisCache) HGet(key, field string) *Cmd {
+	var (
+		getCmd *redis.StringCmd
+		ttlCmd *redis.DurationCmd
+	)
+	_, err := rc.client.Pipelined(func(pipe redis.Pipeliner) error {
+		getCmd = pipe.HGet(key, field)
+		ttlCmd = pipe.PTTL(key)
+		return nil
+	})
+	if err == redis.Nil {
+		return &Cmd{}
+	}
+	if err != nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+	return &Cmd{baseCmd: baseCmd{exists: true, ttl: ttlCmd.Val()}, value: getCmd.Val()}
+}
+
+func (rc *RedisCache) HGetAll(key string) *MapCmd {
+	var (
+		getCmd *redis.StringStringMapCmd
+		ttlCmd *redis.DurationCmd
+	)
+	_, err := rc.client.Pipelined(func(pipe redis.Pipeliner) error {
+		getCmd = pipe.HGetAll(key)
+		ttlCmd = pipe.PTTL(key)
+		return nil
+	})
+	if err != nil {
+		return &MapCmd{baseCmd: baseCmd{err: err}}
+	}
+	result := make(map[string]interface{}, len(getCmd.Val()))
+	for field, value := range getCmd.Val() {
+		result[field] = value
+	}
+	if len(result) == 0 {
+		return &MapCmd{value: result}
+	}
+	return &MapCmd{baseCmd: baseCmd{exists: true, ttl: ttlCmd.Val()}, value: result}
+}
+
+func (rc *RedisCache) HDel(key string, fields ...string) *IntCmd {
+	n, err := rc.client.HDel(key, fields...).Result()
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{value: n}
+}
+
+func (rc *RedisCache) HIncrBy(key, field string, incr int64) *IntCmd {
+	var (
+		incrCmd *redis.IntCmd
+		ttlCmd  *redis.DurationCmd
+	)
+	_, err := rc.client.Pipelined(func(pipe redis.Pipeliner) error {
+		incrCmd = pipe.HIncrBy(key, field, incr)
+		ttlCmd = pipe.PTTL(key)
+		return nil
+	})
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{baseCmd: baseCmd{exists: true, ttl: ttlCmd.Val()}, value: incrCmd.Val()}
+}
+
+func (rc *RedisCache) LPush(key string, values ...interface{}) *IntCmd {
+	return rc.push(rc.client.LPush, key, values)
+}
+
+func (rc *RedisCache) RPush(key string, values ...interface{}) *IntCmd {
+	return rc.push(rc.client.RPush, key, values)
+}
+
+func (rc *RedisCache) push(fn func(string, ...interface{}) *redis.IntCmd, key string, values []interface{}) *IntCmd {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		val, err := encodeRedisValue(value)
+		if err != nil {
+			return &IntCmd{baseCmd: baseCmd{err: err}}
+		}
+		args[i] = val
+	}
+	n, err := fn(key, args...).Result()
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{baseCmd: baseCmd{exists: n > 0}, value: n}
+}
+
+func (rc *RedisCache) LPop(key string) *Cmd {
+	return rc.pop(rc.client.LPop, key)
+}
+
+func (rc *RedisCache) RPop(key string) *Cmd {
+	return rc.pop(rc.client.RPop, key)
+}
+
+func (rc *RedisCache) pop(fn func(string) *redis.StringCmd, key string) *Cmd {
+	val, err := fn(key).Result()
+	if err == redis.Nil {
+		return &Cmd{}
+	}
+	if err != nil {
+		return &Cmd{baseCmd: baseCmd{err: err}}
+	}
+	return &Cmd{baseCmd: baseCmd{exists: true}, value: val}
+}
+
+// redisBLPopSlice 每次 BLPOP 阻塞的最长时间，BLPOP 不会因为 ctx 结束而返回，每次之间检查 ctx
+const redisBLPopSlice = time.Second
+
+// BLPop 分多次 BLPOP 等待，ctx 结束后最多一个 redisBLPopSlice 内返回，redis 的超时精度为秒
+func (rc *RedisCache) BLPop(ctx context.Context, timeout time.Duration, keys ...string) *KeyValueCmd {
+	client := rc.client.WithContext(ctx)
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return &KeyValueCmd{Cmd: Cmd{baseCmd: baseCmd{err: err}}}
+		}
+		// 剩余时间不足 redisBLPopSlice 时也等待 redisBLPopSlice，redis 的超时精度为秒
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return &KeyValueCmd{}
+		}
+		res, err := client.BLPop(redisBLPopSlice, keys...).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			// ctx 有 deadline 时连接的读超时不超过 deadline，超时的错误按 ctx 结束返回
+			if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+				err = context.DeadlineExceeded
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				err = ctxErr
+			}
+			return &KeyValueCmd{Cmd: Cmd{baseCmd: baseCmd{err: err}}}
+		}
+		return &KeyValueCmd{Cmd: Cmd{baseCmd: baseCmd{exists: true}, value: res[1]}, key: res[0]}
+	}
+}
+
+func (rc *RedisCache) LRange(key string, start, stop int64) *SliceCmd {
+	res, err := rc.client.LRange(key, start, stop).Result()
+	if err != nil {
+		return &SliceCmd{baseCmd: baseCmd{err: err}}
+	}
+	values := make([]interface{}, len(res))
+	for i, val := range res {
+		values[i] = val
+	}
+	return &SliceCmd{baseCmd: baseCmd{exists: len(values) > 0}, value: values}
+}
+
+func (rc *RedisCache) LTrim(key string, start, stop int64) *StatusCmd {
+	if err := rc.client.LTrim(key, start, stop).Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &StatusCmd{value: StatusOK}
+}
+
+func (rc *RedisCache) LLen(key string) *IntCmd {
+	n, err := rc.client.LLen(key).Result()
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{baseCmd: baseCmd{exists: n > 0}, value: n}
+}
+
+func (rc *RedisCache) ZAdd(key string, members ...Z) *IntCmd {
+	zs := make([]*redis.Z, len(members))
+	for i, m := range members {
+		zs[i] = &redis.Z{Score: m.Score, Member: m.Member}
+	}
+	n, err := rc.client.ZAdd(key, zs...).Result()
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{baseCmd: baseCmd{exists: true}, value: n}
+}
+
+func (rc *RedisCache) ZIncrBy(key string, increment float64, member string) *FloatCmd {
+	score, err := rc.client.ZIncrBy(key, increment, member).Result()
+	if err != nil {
+		return &FloatCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &FloatCmd{baseCmd: baseCmd{exists: true}, value: score}
+}
+
+func (rc *RedisCache) ZRem(key string, members ...string) *IntCmd {
+	args := make([]interface{}, len(members))
+	for i, member := range members {
+		args[i] = member
+	}
+	n, err := rc.client.ZRem(key, args...).Result()
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{value: n}
+}
+
+func (rc *RedisCache) ZRange(key string, start, stop int64) *ZSliceCmd {
+	return newZSliceCmd(rc.client.ZRangeWithScores(key, start, stop).Result())
+}
+
+func (rc *RedisCache) ZRangeByScore(key string, min, max float64) *ZSliceCmd {
+	return newZSliceCmd(rc.client.ZRangeByScoreWithScores(key, &redis.ZRangeBy{
+		Min: formatScore(min),
+		Max: formatScore(max),
+	}).Result())
+}
+
+func newZSliceCmd(res []redis.Z, err error) *ZSliceCmd {
+	if err != nil {
+		return &ZSliceCmd{baseCmd: baseCmd{err: err}}
+	}
+	values := make([]Z, len(res))
+	for i, z := range res {
+		values[i] = Z{Score: z.Score, Member: z.Member.(string)}
+	}
+	return &ZSliceCmd{baseCmd: baseCmd{exists: len(values) > 0}, value: values}
+}
+
+// formatScore 转换为 redis 的 score 参数，支持无穷
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "+inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+func (rc *RedisCache) ZRank(key, member string) *IntCmd {
+	rank, err := rc.client.ZRank(key, member).Result()
+	if err == redis.Nil {
+		return &IntCmd{}
+	}
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{baseCmd: baseCmd{exists: true}, value: rank}
+}
+
+func (rc *RedisCache) ZScore(key, member string) *FloatCmd {
+	score, err := rc.client.ZScore(key, member).Result()
+	if err == redis.Nil {
+		return &FloatCmd{}
+	}
+	if err != nil {
+		return &FloatCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &FloatCmd{baseCmd: baseCmd{exists: true}, value: score}
+}
+
+func (rc *RedisCache) ZCard(key string) *IntCmd {
+	n, err := rc.client.ZCard(key).Result()
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{baseCmd: baseCmd{exists: n > 0}, value: n}
+}
+
+// tagKeyPrefix 标签对应的 set，保存带有该标签的 key
+const tagKeyPrefix = "gokit:cache:tag:"
+
+// setWithTagsScript 写入 key 并加入每个标签的 set，set 的过期时间不短于 key
+var setWithTagsScript = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+for i = 2, #KEYS do
+	local pttl = redis.call('PTTL', KEYS[i])
+	redis.call('SADD', KEYS[i], KEYS[1])
+	if ttl <= 0 then
+		redis.call('PERSIST', KEYS[i])
+	elseif pttl == -2 or (pttl >= 0 and pttl < ttl) then
+		redis.call('PEXPIRE', KEYS[i], ttl)
+	end
+end
+return 1
+`)
+
+// invalidateTagScript 删除 set 中的所有 key 和 set 本身，返回删除的 key 数量
+var invalidateTagScript = redis.NewScript(`
+local keys = redis.call('SMEMBERS', KEYS[1])
+local n = 0
+for i = 1, #keys, 1000 do
+	n = n + redis.call('DEL', unpack(keys, i, math.min(i + 999, #keys)))
+end
+redis.call('DEL', KEYS[1])
+return n
+`)
+
+// SetWithTags Set 覆盖 key 时不会移除之前的标签，InvalidateTag 时仍会删除该 key
+func (rc *RedisCache) SetWithTags(key string, value interface{}, ttl time.Duration, tags ...string) *StatusCmd {
+	val, err := encodeRedisValue(value)
+	if err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	keys := []string{key}
+	for _, tag := range uniqueTags(tags) {
+		keys = append(keys, tagKeyPrefix+tag)
+	}
+	if err := setWithTagsScript.Run(rc.client, keys, val, ttl.Milliseconds()).Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	if ttl == 0 {
+		ttl = -1
+	}
+	return &StatusCmd{baseCmd: baseCmd{exists: true, ttl: ttl}, value: StatusOK}
+}
+
+func (rc *RedisCache) InvalidateTag(tag string) *IntCmd {
+	n, err := invalidateTagScript.Run(rc.client, []string{tagKeyPrefix + tag}).Int64()
+	if err != nil {
+		return &IntCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &IntCmd{value: n}
+}
+
+// FlushAll 只清空当前 DB
+func (rc *RedisCache) FlushAll() *StatusCmd {
+	return rc.FlushAllContext(context.Background())
+}
+
+func (rc *RedisCache) FlushAllContext(ctx context.Context) *StatusCmd {
+	client := rc.client.WithContext(ctx)
+	if err := client.FlushDB().Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &StatusCmd{value: StatusOK}
+}
+
+// Save 触发 redis 后台持久化
+func (rc *RedisCache) Save() *StatusCmd {
+	return rc.SaveContext(context.Background())
+}
+
+func (rc *RedisCache) SaveContext(ctx context.Context) *StatusCmd {
+	client := rc.client.WithContext(ctx)
+	if err := client.BgSave().Err(); err != nil {
+		return &StatusCmd{baseCmd: baseCmd{err: err}}
+	}
+	return &StatusCmd{value: StatusOK}
+}
+
+func (rc *RedisCache) Close() error {
+	return rc.client.Close()
+}
+
+// encodeRedisValue 与 Cmd.Bytes 编码一致，非基础类型编码为 JSON，不使用 Codec
+func encodeRedisValue(value interface{}) ([]byte, error) {
+	if value == nil {
+		return []byte{}, nil
+	}
+	return toBytes(value)
+}
+
+// escapeGlob 转义 redis 匹配模式中的特殊字符
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,375 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+var _ Cache = (*RedisCache)(nil)
+
+func newTestRedisCache(t *testing.T) (*RedisCache, *miniredis.Miniredis) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	rc := NewRedisCache(RedisOptions{Addr: s.Addr()})
+	t.Cleanup(func() { rc.Close() })
+	return rc, s
+}
+
+func TestRedisCache_GetSet(t *testing.T) {
+	rc, s := newTestRedisCache(t)
+	if setCmd := rc.Set("2s", "true", 2*time.Second); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	getCmd := rc.Get("2s")
+	if getCmd.Error() != nil {
+		t.Fatal(getCmd.Error())
+	}
+	if !getCmd.Exists() || getCmd.ValString() != "true" {
+		t.Fatal(getCmd.ValString())
+	}
+	if getCmd.TTL() <= 0 || getCmd.TTL() > 2*time.Second {
+		t.Fatal(getCmd.TTL())
+	}
+
+	s.FastForward(2 * time.Second)
+
+	getCmd = rc.Get("2s")
+	if getCmd.Error() != nil {
+		t.Fatal(getCmd.Error())
+	}
+	if getCmd.Exists() {
+		t.Fatal("should del key")
+	}
+
+	if setCmd := rc.Set("forever", 1, -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if getCmd := rc.Get("forever"); getCmd.TTL() != -1 || getCmd.ValString() != "1" {
+		t.Fatal(getCmd.TTL(), getCmd.ValString())
+	}
+
+	if setCmd := rc.Set("struct", struct{ A int }{A: 1}, -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if getCmd := rc.Get("struct"); getCmd.ValString() != `{"A":1}` {
+		t.Fatal(getCmd.ValString())
+	}
+}
+
+func TestRedisCache_KeysDeleteFlush(t *testing.T) {
+	rc, _ := newTestRedisCache(t)
+	for _, key := range []string{"user:1", "user:2", "user*", "order:1"} {
+		if setCmd := rc.Set(key, key, -1); setCmd.Error() != nil {
+			t.Fatal(setCmd.Error())
+		}
+	}
+
+	keys := rc.Keys("user:").Val()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "user:1" || keys[1] != "user:2" {
+		t.Fatal(keys)
+	}
+	if keys := rc.Keys("user*").Val(); len(keys) != 1 {
+		t.Fatal(keys)
+	}
+	if keys := rc.Keys("").Val(); len(keys) != 4 {
+		t.Fatal(keys)
+	}
+
+	if delCmd := rc.Delete("user:1"); delCmd.Error() != nil {
+		t.Fatal(delCmd.Error())
+	}
+	if rc.Get("user:1").Exists() {
+		t.Fatal("should del key")
+	}
+
+	if flushCmd := rc.FlushAll(); flushCmd.Error() != nil {
+		t.Fatal(flushCmd.Error())
+	}
+	if keys := rc.Keys("").Val(); len(keys) != 0 {
+		t.Fatal(keys)
+	}
+}
+
+func TestRedisCache_IncrBy(t *testing.T) {
+	rc, s := newTestRedisCache(t)
+	if incrCmd := rc.IncrBy("counter", 2, time.Minute); incrCmd.Error() != nil || incrCmd.Val() != 2 {
+		t.Fatal(incrCmd.Val(), incrCmd.Error())
+	}
+	if incrCmd := rc.DecrBy("counter", 1, time.Hour); incrCmd.Error() != nil || incrCmd.Val() != 1 {
+		t.Fatal(incrCmd.Val(), incrCmd.Error())
+	}
+	// ttl 只在创建时生效
+	if ttl := s.TTL("counter"); ttl != time.Minute {
+		t.Fatal(ttl)
+	}
+	if incrCmd := rc.IncrByFloat("counter", 0.5, -1); incrCmd.Error() != nil || incrCmd.Val() != 1.5 {
+		t.Fatal(incrCmd.Val(), incrCmd.Error())
+	}
+	if incrCmd := rc.IncrBy("counter", 1, -1); incrCmd.Error() == nil {
+		t.Fatal("should not incr float")
+	}
+
+	if incrCmd := rc.IncrBy("forever", 1, -1); incrCmd.Error() != nil || incrCmd.TTL() != -1 {
+		t.Fatal(incrCmd.TTL(), incrCmd.Error())
+	}
+}
+
+func TestRedisCache_SetNX(t *testing.T) {
+	rc, s := newTestRedisCache(t)
+	if !rc.SetNX("job", 1, time.Minute).Val() {
+		t.Fatal("SetNX should write")
+	}
+	if rc.SetNX("job", 2, time.Minute).Val() {
+		t.Fatal("SetNX should not write")
+	}
+	if rc.SetXX("not_exists", 1, -1).Val() {
+		t.Fatal("SetXX should not write")
+	}
+	if !rc.SetXX("job", "done", -1).Val() || rc.Get("job").ValString() != "done" {
+		t.Fatal("SetXX should write")
+	}
+
+	getCmd := rc.GetSet("job", "again", time.Hour)
+	if getCmd.Error() != nil || !getCmd.Exists() || getCmd.ValString() != "done" {
+		t.Fatal(getCmd.ValString(), getCmd.Error())
+	}
+	if ttl := s.TTL("job"); ttl != time.Hour {
+		t.Fatal(ttl)
+	}
+	if getCmd := rc.GetSet("new", 1, -1); getCmd.Error() != nil || getCmd.Exists() {
+		t.Fatal("GetSet new key should not exists")
+	}
+
+	rc.Set("version", 1, -1)
+	if !rc.CompareAndSwap("version", 1, 2, -1).Val() {
+		t.Fatal("CompareAndSwap should write")
+	}
+	if rc.CompareAndSwap("version", 1, 3, -1).Val() || rc.Get("version").ValString() != "2" {
+		t.Fatal("CompareAndSwap should not write")
+	}
+	if rc.CompareAndSwap("not_exists", "", 1, -1).Val() {
+		t.Fatal("CompareAndSwap should not write not exists key")
+	}
+}
+
+func TestRedisCache_MGet(t *testing.T) {
+	rc, _ := newTestRedisCache(t)
+	values := map[string]interface{}{"k1": 1, "k2": "2", "k3": 3.5}
+	if setCmd := rc.MSet(values, time.Minute); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+
+	multiCmd := rc.MGet("k1", "not_exists", "k2", "k3")
+	if multiCmd.Error() != nil {
+		t.Fatal(multiCmd.Error())
+	}
+	cmds := multiCmd.Val()
+	if !cmds[0].Exists() || cmds[0].ValString() != "1" || cmds[0].TTL() != time.Minute {
+		t.Fatal(cmds[0])
+	}
+	if cmds[1].Exists() {
+		t.Fatal("should not exists")
+	}
+	if cmds[2].ValString() != "2" || cmds[3].ValString() != "3.5" {
+		t.Fatal(cmds[2].ValString(), cmds[3].ValString())
+	}
+
+	if delCmd := rc.MDelete("k1", "k2", "not_exists"); delCmd.Error() != nil || delCmd.Val() != 2 {
+		t.Fatal(delCmd.Val(), delCmd.Error())
+	}
+	if setCmd := rc.MSet(map[string]interface{}{}, -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+}
+
+func TestRedisCache_Hash(t *testing.T) {
+	rc, _ := newTestRedisCache(t)
+	if setCmd := rc.HSet("user:1", "name", "tom"); setCmd.Error() != nil || !setCmd.Val() {
+		t.Fatal(setCmd.Val(), setCmd.Error())
+	}
+	if setCmd := rc.HSet("user:1", "name", "jerry"); setCmd.Error() != nil || setCmd.Val() {
+		t.Fatal("name should not be new field")
+	}
+	if getCmd := rc.HGet("user:1", "name"); !getCmd.Exists() || getCmd.ValString() != "jerry" {
+		t.Fatal(getCmd.ValString())
+	}
+	if getCmd := rc.HGet("user:1", "age"); getCmd.Error() != nil || getCmd.Exists() {
+		t.Fatal("age should not exists")
+	}
+	if incrCmd := rc.HIncrBy("user:1", "visits", 2); incrCmd.Error() != nil || incrCmd.Val() != 2 {
+		t.Fatal(incrCmd.Val(), incrCmd.Error())
+	}
+	all := rc.HGetAll("user:1").Val()
+	if len(all) != 2 || all["name"] != "jerry" || all["visits"] != "2" {
+		t.Fatal(all)
+	}
+	if delCmd := rc.HDel("user:1", "name", "visits"); delCmd.Val() != 2 {
+		t.Fatal(delCmd.Val())
+	}
+	if rc.HGetAll("user:1").Exists() {
+		t.Fatal("empty hash should be deleted")
+	}
+}
+
+func TestRedisCache_List(t *testing.T) {
+	rc, _ := newTestRedisCache(t)
+	rc.RPush("queue", 1, 2, 3)
+	if pushCmd := rc.LPush("queue", 0); pushCmd.Error() != nil || pushCmd.Val() != 4 {
+		t.Fatal(pushCmd.Val(), pushCmd.Error())
+	}
+	if values := rc.LRange("queue", 0, -1).Val(); len(values) != 4 || values[0] != "0" || values[3] != "3" {
+		t.Fatal(values)
+	}
+	if popCmd := rc.LPop("queue"); !popCmd.Exists() || popCmd.ValString() != "0" {
+		t.Fatal(popCmd.Val())
+	}
+	if popCmd := rc.RPop("queue"); !popCmd.Exists() || popCmd.ValString() != "3" {
+		t.Fatal(popCmd.Val())
+	}
+	rc.LTrim("queue", 0, 0)
+	if n := rc.LLen("queue").Val(); n != 1 {
+		t.Fatal(n)
+	}
+
+	popCmd := rc.BLPop(context.Background(), time.Second, "empty", "queue")
+	if popCmd.Error() != nil || popCmd.Key() != "queue" || popCmd.ValString() != "1" {
+		t.Fatal(popCmd.Key(), popCmd.Val(), popCmd.Error())
+	}
+	if rc.LPop("queue").Exists() {
+		t.Fatal("queue should be empty")
+	}
+
+	// timeout 为 0 时等待到 ctx 结束
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if popCmd := rc.BLPop(ctx, 0, "queue"); popCmd.Error() != context.DeadlineExceeded || popCmd.Exists() {
+		t.Fatal(popCmd.Error())
+	}
+	if d := time.Since(start); d > redisBLPopSlice+time.Second {
+		t.Fatal(d)
+	}
+
+	// ctx 没有 deadline 时，取消后在一个 redisBLPopSlice 内返回
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start = time.Now()
+	if popCmd := rc.BLPop(ctx, 0, "queue"); popCmd.Error() != context.Canceled {
+		t.Fatal(popCmd.Error())
+	}
+	if d := time.Since(start); d > redisBLPopSlice+time.Second {
+		t.Fatal(d)
+	}
+
+	// 等待期间写入
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		rc.RPush("queue", "2")
+	}()
+	if popCmd := rc.BLPop(context.Background(), 0, "queue"); popCmd.ValString() != "2" {
+		t.Fatal(popCmd.Val(), popCmd.Error())
+	}
+	if popCmd := rc.BLPop(context.Background(), time.Second, "queue"); popCmd.Exists() || popCmd.Error() != nil {
+		t.Fatal(popCmd.Val(), popCmd.Error())
+	}
+}
+
+func TestRedisCache_ZSet(t *testing.T) {
+	rc, _ := newTestRedisCache(t)
+	addCmd := rc.ZAdd("board", Z{Score: 10, Member: "tom"}, Z{Score: 30, Member: "jerry"}, Z{Score: 20, Member: "spike"})
+	if addCmd.Error() != nil || addCmd.Val() != 3 {
+		t.Fatal(addCmd.Val(), addCmd.Error())
+	}
+	if incrCmd := rc.ZIncrBy("board", 30, "tom"); incrCmd.Val() != 40 {
+		t.Fatal(incrCmd.Val())
+	}
+	values := rc.ZRange("board", 0, -1).Val()
+	if len(values) != 3 || values[0].Member != "spike" || values[2].Member != "tom" || values[2].Score != 40 {
+		t.Fatal(values)
+	}
+	if values := rc.ZRangeByScore("board", 20, math.Inf(1)).Val(); len(values) != 3 {
+		t.Fatal(values)
+	}
+	if rankCmd := rc.ZRank("board", "jerry"); !rankCmd.Exists() || rankCmd.Val() != 1 {
+		t.Fatal(rankCmd.Val())
+	}
+	if rankCmd := rc.ZRank("board", "nobody"); rankCmd.Error() != nil || rankCmd.Exists() {
+		t.Fatal("nobody should not exists")
+	}
+	if scoreCmd := rc.ZScore("board", "spike"); scoreCmd.Val() != 20 {
+		t.Fatal(scoreCmd.Val())
+	}
+	if remCmd := rc.ZRem("board", "tom", "nobody"); remCmd.Val() != 1 {
+		t.Fatal(remCmd.Val())
+	}
+	if n := rc.ZCard("board").Val(); n != 2 {
+		t.Fatal(n)
+	}
+}
+
+func TestRedisCache_Tags(t *testing.T) {
+	rc, s := newTestRedisCache(t)
+	rc.SetWithTags("user:42:a", 1, time.Minute, "user:42", "pages")
+	rc.SetWithTags("user:42:b", 2, 0, "user:42")
+	rc.SetWithTags("user:7:a", 3, time.Second, "pages")
+
+	if ttl := s.TTL(tagKeyPrefix + "pages"); ttl != time.Minute {
+		t.Fatal(ttl)
+	}
+	if ttl := s.TTL(tagKeyPrefix + "user:42"); ttl != 0 {
+		t.Fatal("tag set should not expire", ttl)
+	}
+
+	if cmd := rc.InvalidateTag("user:42"); cmd.Error() != nil || cmd.Val() != 2 {
+		t.Fatal(cmd.Val(), cmd.Error())
+	}
+	if s.Exists("user:42:a") || s.Exists("user:42:b") || s.Exists(tagKeyPrefix+"user:42") {
+		t.Fatal("tagged keys should be deleted")
+	}
+	if cmd := rc.InvalidateTag("pages"); cmd.Val() != 1 || s.Exists("user:7:a") {
+		t.Fatal(cmd.Val())
+	}
+	if cmd := rc.InvalidateTag("missing"); cmd.Error() != nil || cmd.Val() != 0 {
+		t.Fatal(cmd.Val(), cmd.Error())
+	}
+}
+
+func TestRedisCache_Expire(t *testing.T) {
+	redisCache, s := newTestRedisCache(t)
+	defer s.Close()
+	redisCache.Set("k1", "v1", time.Minute)
+
+	if cmd := redisCache.Expire("k1", time.Hour); !cmd.Val() {
+		t.Fatal(cmd.Error())
+	}
+	if ttl := s.TTL("k1"); ttl != time.Hour {
+		t.Fatal(ttl)
+	}
+	if cmd := redisCache.Persist("k1"); !cmd.Val() {
+		t.Fatal(cmd.Error())
+	}
+	if cmd := redisCache.Persist("k1"); cmd.Val() {
+		t.Fatal("k1 has no ttl")
+	}
+	if cmd := redisCache.Touch("k1", time.Minute); !cmd.Val() || s.TTL("k1") != time.Minute {
+		t.Fatal(cmd.Error(), s.TTL("k1"))
+	}
+	if cmd := redisCache.Touch("none", time.Minute); cmd.Val() || cmd.Error() != nil {
+		t.Fatal(cmd.Error())
+	}
+	if cmd := redisCache.ExpireAt("k1", time.Now().Add(-time.Second)); !cmd.Val() || cmd.Exists() {
+		t.Fatal(cmd.Error())
+	}
+	if redisCache.Get("k1").Exists() {
+		t.Fatal("k1 should be deleted")
+	}
+}
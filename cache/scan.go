@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+)
+
+/*
+	按游标分批遍历 key
+Notice:
+	1. 每个分片的 key 按哈希分为 scanBuckets 个桶，游标为 分片序号 * scanBuckets + 桶序号，
+	   每次调用只在读取的分片上加读锁，返回的游标为 0 时遍历结束
+	2. 与 redis SCAN 一致，遍历期间一直存在的 key 一定会返回且只返回一次，期间写入或删除的 key 可能返回也可能不返回
+	3. count 只是每次返回数量的参考值，一个桶内的 key 会一次返回，实际数量可能多于 count
+	4. 桶索引额外占用每个 key 一个 map 元素的内存
+	5. pattern 与 redis 一致，支持 *、?、[abc]、[^a]、[a-z] 和 \ 转义，为空时匹配所有 key
+*/
+
+const (
+	// scanBuckets 每个分片的桶数量
+	scanBuckets = 256
+	// DefaultScanCount Scan 的 count <= 0 时使用
+	DefaultScanCount = 10
+)
+
+// scanBucket 使用哈希的高位，分片使用低位
+func scanBucket(key string) int {
+	return int(keyHash(key) >> 24)
+}
+
+// indexLocked 新的 key 加入 Scan 的索引，调用方持有分片写锁
+func (sh *memShard) indexLocked(key string) {
+	i := scanBucket(key)
+	if sh.buckets[i] == nil {
+		sh.buckets[i] = make(map[string]struct{})
+	}
+	sh.buckets[i][key] = struct{}{}
+}
+
+func (sh *memShard) unindexLocked(key string) {
+	i := scanBucket(key)
+	delete(sh.buckets[i], key)
+	if len(sh.buckets[i]) == 0 {
+		sh.buckets[i] = nil
+	}
+}
+
+func (mem *MemCache) Scan(cursor uint64, pattern string, count int64) *ScanCmd {
+	return mem.ScanContext(context.Background(), cursor, pattern, count)
+}
+
+// ScanContext 每个分片之间检查 ctx，不返回已过期的 key
+func (mem *MemCache) ScanContext(ctx context.Context, cursor uint64, pattern string, count int64) *ScanCmd {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	total := uint64(len(mem.shards)) * scanBuckets
+	keys := make([]string, 0, count)
+	for cursor < total && int64(len(keys)) < count {
+		if err := ctx.Err(); err != nil {
+			return &ScanCmd{baseCmd: baseCmd{err: err}}
+		}
+		sh := mem.shards[cursor/scanBuckets]
+		end := (cursor/scanBuckets + 1) * scanBuckets
+		sh.rwMutex.RLock()
+		for ; cursor < end && int64(len(keys)) < count; cursor++ {
+			for key := range sh.buckets[cursor%scanBuckets] {
+				val := sh.store[key]
+				if val.Expired() || !matchGlob(pattern, key) {
+					continue
+				}
+				keys = append(keys, key)
+			}
+		}
+		sh.rwMutex.RUnlock()
+	}
+	if cursor >= total {
+		cursor = 0
+	}
+	return &ScanCmd{value: keys, cursor: cursor}
+}
+
+// matchGlob 与 redis 的 stringmatch 一致，按字节匹配
+func matchGlob(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	return globMatch(pattern, s)
+}
+
+func globMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if globMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			var ok bool
+			if ok, pattern = matchClass(pattern[1:], s[0]); !ok {
+				return false
+			}
+			s = s[1:]
+		default:
+			if pattern[0] == '\\' && len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// matchClass pattern 为 [ 之后的部分，返回是否匹配以及 ] 之后的部分
+func matchClass(pattern string, c byte) (bool, string) {
+	not := len(pattern) > 0 && pattern[0] == '^'
+	if not {
+		pattern = pattern[1:]
+	}
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) >= 2:
+			matched = matched || pattern[1] == c
+			pattern = pattern[2:]
+		case len(pattern) >= 3 && pattern[1] == '-':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (c >= lo && c <= hi)
+			pattern = pattern[3:]
+		default:
+			matched = matched || pattern[0] == c
+			pattern = pattern[1:]
+		}
+	}
+	// 没有 ] 时到 pattern 结尾
+	if len(pattern) > 0 {
+		pattern = pattern[1:]
+	}
+	return matched != not, pattern
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"", "anything", true},
+		{"*", "", true},
+		{"user:*", "user:1", true},
+		{"user:*", "order:1", false},
+		{"*:1", "user:1", true},
+		{"u*r:*1", "user:21", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[c-a]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`h[\]]llo`, "h]llo", true},
+		{"a**b", "axxb", true},
+		{"abc", "abcd", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v", tt.pattern, tt.key, got)
+		}
+	}
+}
+
+// scanAll 遍历到 cursor 为 0，fn 在每次 Scan 之后调用
+func scanAll(t *testing.T, c Cache, pattern string, fn func()) []string {
+	keys := make([]string, 0)
+	cursor := uint64(0)
+	for {
+		scanCmd := c.Scan(cursor, pattern, 10)
+		if scanCmd.Error() != nil {
+			t.Fatal(scanCmd.Error())
+		}
+		keys = append(keys, scanCmd.Val()...)
+		if cursor = scanCmd.Cursor(); cursor == 0 {
+			break
+		}
+		fn()
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestMemCache_Scan(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Shards = 4
+	memCache := NewMemCache(opt)
+	for i := 0; i < 100; i++ {
+		memCache.Set(fmt.Sprintf("user:%d", i), i, -1)
+		memCache.Set(fmt.Sprintf("order:%d", i), i, -1)
+	}
+	memCache.Set("user:expired", 1, time.Millisecond)
+	memCache.HSet("user:hash", "f", "v")
+	time.Sleep(5 * time.Millisecond)
+
+	keys := scanAll(t, memCache, "user:*", func() {})
+	if len(keys) != 101 {
+		t.Fatal(len(keys), keys)
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i] == keys[i-1] {
+			t.Fatal("duplicate key", keys[i])
+		}
+	}
+
+	// 遍历期间的写入和删除不影响一直存在的 key
+	n := 0
+	keys = scanAll(t, memCache, "order:?", func() {
+		memCache.Set(fmt.Sprintf("new:%d", n), n, -1)
+		memCache.Delete(fmt.Sprintf("order:%d", 10+n))
+		n++
+	})
+	if len(keys) != 10 {
+		t.Fatal(keys)
+	}
+
+	memCache.FlushAll()
+	if scanCmd := memCache.Scan(0, "", 10); len(scanCmd.Val()) != 0 || scanCmd.Cursor() != 0 {
+		t.Fatal(scanCmd.Val(), scanCmd.Cursor())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if scanCmd := memCache.ScanContext(ctx, 0, "", 10); scanCmd.Error() != context.Canceled {
+		t.Fatal(scanCmd.Error())
+	}
+}
+
+func TestRedisCache_Scan(t *testing.T) {
+	redisCache, s := newTestRedisCache(t)
+	defer s.Close()
+	for i := 0; i < 30; i++ {
+		redisCache.Set(fmt.Sprintf("user:%d", i), i, -1)
+	}
+	redisCache.Set("order:1", 1, -1)
+
+	if keys := scanAll(t, redisCache, "user:*", func() {}); len(keys) != 30 {
+		t.Fatal(keys)
+	}
+	if keys := scanAll(t, WithoutContext(WithContext(redisCache)), "order:[0-9]", func() {}); len(keys) != 1 {
+		t.Fatal(keys)
+	}
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"sync"
+)
+
+// memShard 一个分片，拥有独立的锁和淘汰策略
+type memShard struct {
+	rwMutex sync.RWMutex
+	store   map[string]WrapValue
+
+	// 淘汰策略，nil 表示不淘汰
+	evictor evictor
+
+	// 标签索引 tag -> 分片内带有该标签的 key
+	tags map[string]map[string]struct{}
+
+	// 带有过期时间的 key，用于抽样清理
+	volatile map[string]struct{}
+
+	// Scan 使用的索引，按 key 的哈希分为 scanBuckets 个桶，桶在使用时创建
+	buckets []map[string]struct{}
+
+	// 持有写锁期间删除的 key，释放锁后执行回调
+	events []removeEvent
+
+	// 分片的容量和 key 数量限制，-1 - 不限制，只在分片并开启淘汰策略时设置
+	size        int64
+	currentSize int64
+	maxEntries  int64
+	entries     int64
+}
+
+func newMemShard(policy EvictionPolicy) *memShard {
+	return &memShard{
+		store:      make(map[string]WrapValue),
+		evictor:    newEvictor(policy),
+		tags:       make(map[string]map[string]struct{}),
+		volatile:   make(map[string]struct{}),
+		buckets:    make([]map[string]struct{}, scanBuckets),
+		size:       -1,
+		maxEntries: -1,
+	}
+}
+
+// splitLimit 将总量平均分配到 n 个分片，第 i 个分片分到的数量，n 不能大于 limit
+func splitLimit(limit int64, n, i int) int64 {
+	if limit <= 0 {
+		return -1
+	}
+	part := limit / int64(n)
+	if int64(i) < limit%int64(n) {
+		part++
+	}
+	return part
+}
+
+// tagLocked 将 key 加入标签索引，调用方持有分片写锁
+func (sh *memShard) tagLocked(key string, tags []string) {
+	for _, tag := range tags {
+		if sh.tags[tag] == nil {
+			sh.tags[tag] = make(map[string]struct{})
+		}
+		sh.tags[tag][key] = struct{}{}
+	}
+}
+
+// untagLocked 将 key 从标签索引中移除，调用方持有分片写锁
+func (sh *memShard) untagLocked(key string, tags []string) {
+	for _, tag := range tags {
+		delete(sh.tags[tag], key)
+		if len(sh.tags[tag]) == 0 {
+			delete(sh.tags, tag)
+		}
+	}
+}
+
+// shardIndex 计算 key 所在分片
+func shardIndex(key string, n int) int {
+	if n == 1 {
+		return 0
+	}
+	return int(keyHash(key) % uint32(n))
+}
+
+// keyHash fnv-1a
+func keyHash(key string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return hash
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// errLoaderPanic loader panic 时，其他等待的调用方收到该错误
+var errLoaderPanic = errors.New("cache: loader panicked")
+
+type flightCall struct {
+	wg  sync.WaitGroup
+	cmd *Cmd
+}
+
+// singleflight 同一个 key 同时只执行一次 fn，其他调用方等待并共享结果
+type singleflight struct {
+	mutex sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do 返回 fn 的结果，shared 表示结果是否由其他调用方执行得到
+func (g *singleflight) do(key string, fn func() *Cmd) (cmd *Cmd, shared bool) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		c.wg.Wait()
+		return c.cmd, true
+	}
+	c := &flightCall{cmd: &Cmd{baseCmd: baseCmd{err: errLoaderPanic}}}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mutex.Unlock()
+
+	defer func() {
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		c.wg.Done()
+	}()
+	c.cmd = fn()
+	return c.cmd, false
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"reflect"
+	"time"
+)
+
+// Sizer 估算 key 和 value 占用的字节数，用于容量限制
+type Sizer interface {
+	Size(key string, value interface{}) int64
+}
+
+// SizerFunc 函数实现 Sizer
+type SizerFunc func(key string, value interface{}) int64
+
+func (f SizerFunc) Size(key string, value interface{}) int64 {
+	return f(key, value)
+}
+
+// DefaultSizer 只计算数据本身的字节数，不计算指针、map 等结构的额外开销
+// string、[]byte 为长度，数字为类型的宽度，结构体、slice、map 递归计算
+var DefaultSizer Sizer = SizerFunc(func(key string, value interface{}) int64 {
+	return int64(len(key)) + sizeOfValue(value)
+})
+
+func sizeOfValue(value interface{}) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case time.Time:
+		return 24
+	}
+	return sizeOfReflect(reflect.ValueOf(value), make(map[uintptr]struct{}))
+}
+
+// sizeOfReflect seen 记录访问过的指针，避免循环引用
+func sizeOfReflect(v reflect.Value, seen map[uintptr]struct{}) int64 {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		if v.Kind() == reflect.Ptr {
+			if _, ok := seen[v.Pointer()]; ok {
+				return 0
+			}
+			seen[v.Pointer()] = struct{}{}
+		}
+		return sizeOfReflect(v.Elem(), seen)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return 0
+		}
+		// 元素是定长类型时直接相乘
+		if elem := v.Type().Elem(); isFixedSize(elem) {
+			return int64(v.Len()) * int64(elem.Size())
+		}
+		size := int64(0)
+		for i := 0; i < v.Len(); i++ {
+			size += sizeOfReflect(v.Index(i), seen)
+		}
+		return size
+	case reflect.Map:
+		size := int64(0)
+		iter := v.MapRange()
+		for iter.Next() {
+			size += sizeOfReflect(iter.Key(), seen) + sizeOfReflect(iter.Value(), seen)
+		}
+		return size
+	case reflect.Struct:
+		if isFixedSize(v.Type()) {
+			return int64(v.Type().Size())
+		}
+		size := int64(0)
+		for i := 0; i < v.NumField(); i++ {
+			size += sizeOfReflect(v.Field(i), seen)
+		}
+		return size
+	}
+	return int64(v.Type().Size())
+}
+
+// isFixedSize 类型不包含 string、slice、map、指针等变长数据
+func isFixedSize(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return isFixedSize(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !isFixedSize(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
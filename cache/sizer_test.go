@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultSizer(t *testing.T) {
+	type user struct {
+		ID    int64
+		Name  string
+		Tags  []string
+		Score float32
+		Next  *user
+	}
+	u := &user{ID: 1, Name: "tom", Tags: []string{"a", "bc"}, Score: 1}
+	// 循环引用只计算一次
+	u.Next = u
+
+	cases := []struct {
+		value interface{}
+		size  int64
+	}{
+		{nil, 0},
+		{"hello", 5},
+		{[]byte("hello"), 5},
+		{int8(1), 1},
+		{1, 8},
+		{1.5, 8},
+		{true, 1},
+		{time.Now(), 24},
+		{[]int32{1, 2, 3}, 12},
+		{map[string]interface{}{"a": "xy", "b": 1}, 12},
+		{u, 8 + 3 + 3 + 4},
+		// 副本的 Next 指向原来的 u
+		{*u, 2 * (8 + 3 + 3 + 4)},
+	}
+	for _, c := range cases {
+		if size := DefaultSizer.Size("k", c.value); size != 1+c.size {
+			t.Fatalf("%#v: %d != %d", c.value, size, 1+c.size)
+		}
+	}
+}
+
+func TestMemCache_Sizer(t *testing.T) {
+	opt := NewDefaultOptions()
+	// 超过 int32 的容量
+	opt.Size = 3 << 30
+	opt.Sizer = SizerFunc(func(key string, value interface{}) int64 {
+		return 1 << 30
+	})
+	memCache := NewMemCache(opt)
+	for _, key := range []string{"a", "b", "c"} {
+		if setCmd := memCache.Set(key, 1, -1); setCmd.Error() != nil {
+			t.Fatal(setCmd.Error())
+		}
+	}
+	if setCmd := memCache.Set("d", 1, -1); setCmd.Error() != ErrKeysOverCapacity {
+		t.Fatal("should over size")
+	}
+}
+
+func TestMemCache_MaxEntries(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.MaxEntries = 2
+	memCache := NewMemCache(opt)
+	memCache.Set("a", 1, -1)
+	memCache.HSet("b", "f", 1)
+	if setCmd := memCache.Set("c", 1, -1); setCmd.Error() != ErrKeysOverCapacity {
+		t.Fatal("should over max entries")
+	}
+	// 覆盖已有的 key 不占用数量
+	if setCmd := memCache.Set("a", 2, -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if setCmd := memCache.HSet("b", "g", 1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	memCache.Delete("a")
+	if setCmd := memCache.Set("c", 1, -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+
+	// 开启淘汰时淘汰其他 key
+	opt.Eviction = EvictionLRU
+	memCache = NewMemCache(opt)
+	memCache.Set("a", 1, -1)
+	memCache.Set("b", 1, -1)
+	memCache.Get("a")
+	if setCmd := memCache.Set("c", 1, -1); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if memCache.Get("b").Exists() || !memCache.Get("a").Exists() || memCache.EvictedCount() != 1 {
+		t.Fatal("lru should evict b")
+	}
+	memCache.FlushAll()
+	memCache.Set("x", 1, -1)
+	memCache.Set("y", 1, -1)
+	if memCache.EvictedCount() != 1 {
+		t.Fatal("flush should reset entries")
+	}
+}
@@ -0,0 +1,279 @@
+package cache
+
+import (
+	"encoding/json"
+	"math/rand"
+)
+
+/*
+	有序集合，实现与 redis zset 相同
+Notice:
+	1. dict 保存 member 对应的 score
+	2. skiplist 按 score、member 排序，记录 span 用于按排名查询
+*/
+
+const (
+	skiplistMaxLevel = 32
+	skiplistP        = 0.25
+)
+
+type skiplistLevel struct {
+	forward *skiplistNode
+	// span 到 forward 跨过的节点数
+	span int
+}
+
+type skiplistNode struct {
+	member   string
+	score    float64
+	backward *skiplistNode
+	level    []skiplistLevel
+}
+
+type skiplist struct {
+	header *skiplistNode
+	tail   *skiplistNode
+	length int
+	level  int
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		header: &skiplistNode{level: make([]skiplistLevel, skiplistMaxLevel)},
+		level:  1,
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+	return level
+}
+
+// before node 是否排在 (score, member) 之前
+func (node *skiplistNode) before(score float64, member string) bool {
+	return node.score < score || (node.score == score && node.member < member)
+}
+
+func (sl *skiplist) insert(score float64, member string) *skiplistNode {
+	var (
+		update [skiplistMaxLevel]*skiplistNode
+		rank   [skiplistMaxLevel]int
+	)
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = sl.header
+			update[i].level[i].span = sl.length
+		}
+		sl.level = level
+	}
+
+	x = &skiplistNode{member: member, score: score, level: make([]skiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < sl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != sl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		sl.tail = x
+	}
+	sl.length++
+	return x
+}
+
+func (sl *skiplist) delete(score float64, member string) bool {
+	var update [skiplistMaxLevel]*skiplistNode
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	x = x.level[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+
+	for i := 0; i < sl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		sl.tail = x.backward
+	}
+	for sl.level > 1 && sl.header.level[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+	return true
+}
+
+// rank 从 1 开始的排名，不存在返回 0
+func (sl *skiplist) rank(score float64, member string) int {
+	rank := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			(x.level[i].forward.before(score, member) ||
+				(x.level[i].forward.score == score && x.level[i].forward.member == member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != sl.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank 从 1 开始的排名对应的节点
+func (sl *skiplist) byRank(rank int) *skiplistNode {
+	traversed := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank && x != sl.header {
+			return x
+		}
+	}
+	return nil
+}
+
+// firstInRange score 在 [min, max] 内的第一个节点
+func (sl *skiplist) firstInRange(min, max float64) *skiplistNode {
+	if min > max {
+		return nil
+	}
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.score < min {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if x == nil || x.score > max {
+		return nil
+	}
+	return x
+}
+
+// Z 有序集合的成员
+type Z struct {
+	Score  float64 `json:"score"`
+	Member string  `json:"member"`
+}
+
+type zset struct {
+	dict map[string]float64
+	zsl  *skiplist
+}
+
+func newZSet() interface{} {
+	return &zset{
+		dict: make(map[string]float64),
+		zsl:  newSkiplist(),
+	}
+}
+
+// MarshalJSON 按顺序保存为数组
+func (z *zset) MarshalJSON() ([]byte, error) {
+	return json.Marshal(z.rangeByRank(0, -1))
+}
+
+func (z *zset) len() int {
+	return len(z.dict)
+}
+
+// add 返回是否是新增的 member
+func (z *zset) add(member string, score float64) bool {
+	if old, ok := z.dict[member]; ok {
+		if old != score {
+			z.zsl.delete(old, member)
+			z.zsl.insert(score, member)
+			z.dict[member] = score
+		}
+		return false
+	}
+	z.zsl.insert(score, member)
+	z.dict[member] = score
+	return true
+}
+
+func (z *zset) remove(member string) bool {
+	score, ok := z.dict[member]
+	if !ok {
+		return false
+	}
+	z.zsl.delete(score, member)
+	delete(z.dict, member)
+	return true
+}
+
+// rank 从 0 开始的排名
+func (z *zset) rank(member string) (int, bool) {
+	score, ok := z.dict[member]
+	if !ok {
+		return 0, false
+	}
+	return z.zsl.rank(score, member) - 1, true
+}
+
+// rangeByRank 从 0 开始的排名 [start, stop]，负数表示从尾部开始计算
+func (z *zset) rangeByRank(start, stop int64) []Z {
+	values := make([]Z, 0)
+	from, to, ok := listRange(start, stop, z.len())
+	if !ok {
+		return values
+	}
+	x := z.zsl.byRank(from + 1)
+	for i := from; i <= to && x != nil; i++ {
+		values = append(values, Z{Score: x.score, Member: x.member})
+		x = x.level[0].forward
+	}
+	return values
+}
+
+// rangeByScore score 在 [min, max] 内的成员
+func (z *zset) rangeByScore(min, max float64) []Z {
+	values := make([]Z, 0)
+	for x := z.zsl.firstInRange(min, max); x != nil && x.score <= max; x = x.level[0].forward {
+		values = append(values, Z{Score: x.score, Member: x.member})
+	}
+	return values
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+/*
+	缓存统计
+Notice:
+	1. MemCache 的命中和未命中只统计 Get 和 MGet，集合类型的读取不计入
+	2. MemCache 每次写入计为一次 Sets，包括集合类型的修改；Deletes 包括 Delete、MDelete、InvalidateTag 和 FlushAll
+	3. 已过期的 key 无论因为什么被删除都计为 Expirations，与删除回调一致
+	4. RedisCache 通过 INFO 读取整个 redis 实例的统计，Sets 和 Deletes 为写入和删除类命令的调用次数，
+	   Keys 为当前 db 的 key 数量
+*/
+
+// Stats 统计信息，次数从创建 cache 开始累计
+type Stats struct {
+	Hits        int64
+	Misses      int64
+	Sets        int64
+	Deletes     int64
+	Expirations int64
+	Evictions   int64
+	// 当前的 key 数量，MemCache 包含已过期但还没有清理的 key
+	Keys int64
+	// 估算占用的字节数
+	Bytes int64
+}
+
+type memStats struct {
+	hits        int64
+	misses      int64
+	sets        int64
+	deletes     int64
+	expirations int64
+	evictions   int64
+}
+
+func (s *memStats) removed(reason RemoveReason) {
+	switch reason {
+	case RemoveExpired:
+		atomic.AddInt64(&s.expirations, 1)
+	case RemoveEvicted:
+		atomic.AddInt64(&s.evictions, 1)
+	case RemoveDeleted, RemoveFlushed:
+		atomic.AddInt64(&s.deletes, 1)
+	}
+}
+
+func (s *memStats) reset() {
+	for _, n := range []*int64{&s.hits, &s.misses, &s.sets, &s.deletes, &s.expirations, &s.evictions} {
+		atomic.StoreInt64(n, 0)
+	}
+}
+
+func (mem *MemCache) Stats() *StatsCmd {
+	return &StatsCmd{value: Stats{
+		Hits:        atomic.LoadInt64(&mem.stats.hits),
+		Misses:      atomic.LoadInt64(&mem.stats.misses),
+		Sets:        atomic.LoadInt64(&mem.stats.sets),
+		Deletes:     atomic.LoadInt64(&mem.stats.deletes),
+		Expirations: atomic.LoadInt64(&mem.stats.expirations),
+		Evictions:   atomic.LoadInt64(&mem.stats.evictions),
+		Keys:        atomic.LoadInt64(&mem.entries),
+		Bytes:       atomic.LoadInt64(&mem.currentSize),
+	}}
+}
+
+var (
+	// redisSetCommands 计入 Sets 的命令
+	redisSetCommands = []string{"set", "setnx", "setex", "psetex", "mset", "getset", "incrby", "decrby", "incrbyfloat",
+		"hset", "hincrby", "lpush", "rpush", "zadd", "zincrby", "eval", "evalsha"}
+	// redisDelCommands 计入 Deletes 的命令
+	redisDelCommands = []string{"del", "unlink"}
+)
+
+func (rc *RedisCache) Stats() *StatsCmd {
+	info, err := rc.client.Info("all").Result()
+	if err != nil {
+		return &StatsCmd{baseCmd: baseCmd{err: err}}
+	}
+	keys, err := rc.client.DBSize().Result()
+	if err != nil {
+		return &StatsCmd{baseCmd: baseCmd{err: err}}
+	}
+	fields := parseRedisInfo(info)
+	stats := Stats{
+		Hits:        infoInt(fields, "keyspace_hits"),
+		Misses:      infoInt(fields, "keyspace_misses"),
+		Expirations: infoInt(fields, "expired_keys"),
+		Evictions:   infoInt(fields, "evicted_keys"),
+		Keys:        keys,
+		Bytes:       infoInt(fields, "used_memory"),
+	}
+	for _, name := range redisSetCommands {
+		stats.Sets += commandCalls(fields, name)
+	}
+	for _, name := range redisDelCommands {
+		stats.Deletes += commandCalls(fields, name)
+	}
+	return &StatsCmd{value: stats}
+}
+
+// parseRedisInfo 解析 INFO 返回的 key:value，忽略 # 开头的分组名
+func parseRedisInfo(info string) map[string]string {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.IndexByte(line, ':'); i > 0 {
+			fields[line[:i]] = line[i+1:]
+		}
+	}
+	return fields
+}
+
+func infoInt(fields map[string]string, name string) int64 {
+	n, _ := strconv.ParseInt(fields[name], 10, 64)
+	return n
+}
+
+// commandCalls 读取 cmdstat_<name>:calls=1,usec=2,... 中的 calls
+func commandCalls(fields map[string]string, name string) int64 {
+	for _, kv := range strings.Split(fields["cmdstat_"+name], ",") {
+		if strings.HasPrefix(kv, "calls=") {
+			n, _ := strconv.ParseInt(strings.TrimPrefix(kv, "calls="), 10, 64)
+			return n
+		}
+	}
+	return 0
+}
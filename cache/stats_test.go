@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemCache_Stats(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.MaxEntries = 3
+	opt.Eviction = EvictionLRU
+	memCache := NewMemCache(opt)
+
+	memCache.Set("k1", "v1", -1)
+	memCache.Set("k2", "v2", -1)
+	memCache.Set("expired", 1, time.Millisecond)
+	// 淘汰最久未访问的 k1
+	memCache.HSet("h", "f", "v")
+	memCache.Get("k1")
+	memCache.Get("none")
+	memCache.MGet("k1", "k2", "none")
+	memCache.Delete("k2")
+	time.Sleep(5 * time.Millisecond)
+	memCache.Get("expired")
+
+	stats := memCache.Stats().Val()
+	want := Stats{Hits: 1, Misses: 5, Sets: 4, Deletes: 1, Expirations: 1, Evictions: 1, Keys: 1}
+	want.Bytes = stats.Bytes
+	if stats != want || stats.Bytes <= 0 {
+		t.Fatalf("%+v", stats)
+	}
+
+	memCache.FlushAll()
+	stats = memCache.Stats().Val()
+	if stats.Deletes != 2 || stats.Keys != 0 || stats.Bytes != 0 {
+		t.Fatalf("%+v", stats)
+	}
+}
+
+func TestParseRedisInfo(t *testing.T) {
+	info := "# Stats\r\nkeyspace_hits:10\r\nkeyspace_misses:2\r\nexpired_keys:3\r\nevicted_keys:4\r\n" +
+		"# Memory\r\nused_memory:1024\r\n" +
+		"# Commandstats\r\ncmdstat_set:calls=5,usec=10,usec_per_call=2.00\r\ncmdstat_mset:calls=1,usec=1\r\ncmdstat_del:calls=2,usec=3\r\n"
+	fields := parseRedisInfo(info)
+	if infoInt(fields, "keyspace_hits") != 10 || infoInt(fields, "used_memory") != 1024 {
+		t.Fatal(fields)
+	}
+	if n := commandCalls(fields, "set") + commandCalls(fields, "mset"); n != 6 {
+		t.Fatal(n)
+	}
+	if n := commandCalls(fields, "del"); n != 2 {
+		t.Fatal(n)
+	}
+	if n := commandCalls(fields, "unlink"); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	memCache := NewMemCache()
+	memCache.Set("k1", "v1", -1)
+	memCache.Get("k1")
+	handler := NewMetricsHandler("users", memCache).Register(`a"b`, NewMemCache())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatal(w.Code, w.Header())
+	}
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE gokit_cache_hits_total counter\n",
+		`gokit_cache_hits_total{cache="users"} 1` + "\n",
+		`gokit_cache_keys{cache="users"} 1` + "\n",
+		`gokit_cache_keys{cache="a\"b"} 0` + "\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Fatal(body)
+		}
+	}
+
+	// redis 执行 INFO 失败时返回 500
+	redisCache, s := newTestRedisCache(t)
+	defer s.Close()
+	w = httptest.NewRecorder()
+	NewMetricsHandler("redis", redisCache).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != 500 {
+		t.Fatal(w.Code)
+	}
+}
@@ -0,0 +1,334 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+/*
+	两级缓存，L1 为本地 MemCache，L2 为共享的 RedisCache
+Notice:
+	1. 读取先查 L1，未命中再查 L2，L2 命中后以较短的 LocalTTL 写入 L1
+	2. 写入、删除同时作用于两级，并通过 redis pub/sub 通知其他实例删除 L1 中的副本
+	3. 原子操作（IncrBy、SetNX、CompareAndSwap 等）和修改过期时间只在 L2 执行，执行后删除 L1 中的副本
+	4. 通知是异步的，其他实例在收到通知前可能读到旧值，最长不超过 LocalTTL
+*/
+
+const (
+	DefaultTieredLocalTTL = time.Minute
+	DefaultTieredChannel  = "gokit:cache:invalidate"
+)
+
+type TieredOptions struct {
+	// LocalTTL L1 中副本的最长有效期，<= 0 时使用 DefaultTieredLocalTTL
+	LocalTTL time.Duration
+	// Channel 失效通知的 pub/sub channel，为空时使用 DefaultTieredChannel
+	Channel string
+}
+
+// invalidateMessage 失效通知，ID 用于忽略自己发出的通知
+type invalidateMessage struct {
+	ID    string   `json:"id"`
+	Keys  []string `json:"keys,omitempty"`
+	Flush bool     `json:"flush,omitempty"`
+}
+
+type TieredCache struct {
+	local    *MemCache
+	remote   *RedisCache
+	localTTL time.Duration
+	channel  string
+	id       string
+
+	pubsub *redis.PubSub
+	wg     sync.WaitGroup
+}
+
+// NewTieredCache 订阅失效通知成功后返回，Close 时同时关闭 local 和 remote
+func NewTieredCache(local *MemCache, remote *RedisCache, opts TieredOptions) (*TieredCache, error) {
+	if opts.LocalTTL <= 0 {
+		opts.LocalTTL = DefaultTieredLocalTTL
+	}
+	if opts.Channel == "" {
+		opts.Channel = DefaultTieredChannel
+	}
+	id, err := newInstanceID()
+	if err != nil {
+		return nil, err
+	}
+
+	pubsub := remote.Client().Subscribe(opts.Channel)
+	// 等待订阅确认，避免错过之后的通知
+	if _, err := pubsub.Receive(); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	tc := &TieredCache{
+		local:    local,
+		remote:   remote,
+		localTTL: opts.LocalTTL,
+		channel:  opts.Channel,
+		id:       id,
+		pubsub:   pubsub,
+	}
+	tc.wg.Add(1)
+	go tc.subscribe()
+	return tc, nil
+}
+
+func newInstanceID() (string, error) {
+	byt := make([]byte, 8)
+	if _, err := rand.Read(byt); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(byt), nil
+}
+
+// Local 返回 L1
+func (tc *TieredCache) Local() *MemCache {
+	return tc.local
+}
+
+// Remote 返回 L2
+func (tc *TieredCache) Remote() *RedisCache {
+	return tc.remote
+}
+
+func (tc *TieredCache) subscribe() {
+	defer tc.wg.Done()
+	for msg := range tc.pubsub.Channel() {
+		var m invalidateMessage
+		if err := json.Unmarshal([]byte(msg.Payload), &m); err != nil || m.ID == tc.id {
+			continue
+		}
+		if m.Flush {
+			tc.local.FlushAll()
+			continue
+		}
+		tc.local.MDelete(m.Keys...)
+	}
+}
+
+// publish 通知其他实例，失败时只能等待 L1 过期
+func (tc *TieredCache) publish(m invalidateMessage) {
+	m.ID = tc.id
+	byt, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	tc.remote.Client().Publish(tc.channel, byt)
+}
+
+// invalidate 删除本地副本并通知其他实例
+func (tc *TieredCache) invalidate(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	tc.local.MDelete(keys...)
+	tc.publish(invalidateMessage{Keys: keys})
+}
+
+// localTTLOf L1 的有效期不超过 L2 剩余的有效期
+func (tc *TieredCache) localTTLOf(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > tc.localTTL {
+		return tc.localTTL
+	}
+	return ttl
+}
+
+func (tc *TieredCache) Get(key string) *Cmd {
+	if cmd := tc.local.Get(key); cmd.Exists() && cmd.Error() == nil {
+		return cmd
+	}
+	cmd := tc.remote.Get(key)
+	if cmd.Exists() && cmd.Error() == nil {
+		tc.local.Set(key, cmd.Val(), tc.localTTLOf(cmd.TTL()))
+	}
+	return cmd
+}
+
+func (tc *TieredCache) Set(key string, value interface{}, ttl time.Duration) *StatusCmd {
+	cmd := tc.remote.Set(key, value, ttl)
+	if cmd.Error() != nil {
+		tc.invalidate(key)
+		return cmd
+	}
+	tc.local.Set(key, value, tc.localTTLOf(ttl))
+	tc.publish(invalidateMessage{Keys: []string{key}})
+	return cmd
+}
+
+// Keys 以 L2 为准
+func (tc *TieredCache) Keys(prefix string) *SliceStringCmd {
+	return tc.remote.Keys(prefix)
+}
+
+// Scan 以 L2 为准
+func (tc *TieredCache) Scan(cursor uint64, pattern string, count int64) *ScanCmd {
+	return tc.remote.Scan(cursor, pattern, count)
+}
+
+func (tc *TieredCache) Delete(key string) *StatusCmd {
+	cmd := tc.remote.Delete(key)
+	tc.invalidate(key)
+	return cmd
+}
+
+// Expire 修改 L2 的过期时间后删除 L1 中的副本
+func (tc *TieredCache) Expire(key string, ttl time.Duration) *BoolCmd {
+	cmd := tc.remote.Expire(key, ttl)
+	tc.invalidate(key)
+	return cmd
+}
+
+func (tc *TieredCache) ExpireAt(key string, tm time.Time) *BoolCmd {
+	cmd := tc.remote.ExpireAt(key, tm)
+	tc.invalidate(key)
+	return cmd
+}
+
+func (tc *TieredCache) Persist(key string) *BoolCmd {
+	cmd := tc.remote.Persist(key)
+	tc.invalidate(key)
+	return cmd
+}
+
+func (tc *TieredCache) Touch(key string, ttl time.Duration) *BoolCmd {
+	cmd := tc.remote.Touch(key, ttl)
+	tc.invalidate(key)
+	return cmd
+}
+
+func (tc *TieredCache) IncrBy(key string, value int64, ttl time.Duration) *IntCmd {
+	cmd := tc.remote.IncrBy(key, value, ttl)
+	tc.invalidate(key)
+	return cmd
+}
+
+func (tc *TieredCache) DecrBy(key string, value int64, ttl time.Duration) *IntCmd {
+	cmd := tc.remote.DecrBy(key, value, ttl)
+	tc.invalidate(key)
+	return cmd
+}
+
+func (tc *TieredCache) IncrByFloat(key string, value float64, ttl time.Duration) *FloatCmd {
+	cmd := tc.remote.IncrByFloat(key, value, ttl)
+	tc.invalidate(key)
+	return cmd
+}
+
+func (tc *TieredCache) SetNX(key string, value interface{}, ttl time.Duration) *BoolCmd {
+	cmd := tc.remote.SetNX(key, value, ttl)
+	if cmd.Val() {
+		tc.invalidate(key)
+	}
+	return cmd
+}
+
+func (tc *TieredCache) SetXX(key string, value interface{}, ttl time.Duration) *BoolCmd {
+	cmd := tc.remote.SetXX(key, value, ttl)
+	if cmd.Val() {
+		tc.invalidate(key)
+	}
+	return cmd
+}
+
+func (tc *TieredCache) GetSet(key string, value interface{}, ttl time.Duration) *Cmd {
+	cmd := tc.remote.GetSet(key, value, ttl)
+	tc.invalidate(key)
+	return cmd
+}
+
+func (tc *TieredCache) CompareAndSwap(key string, old, new interface{}, ttl time.Duration) *BoolCmd {
+	cmd := tc.remote.CompareAndSwap(key, old, new, ttl)
+	if cmd.Val() {
+		tc.invalidate(key)
+	}
+	return cmd
+}
+
+// MGet L1 未命中的 key 批量从 L2 读取
+func (tc *TieredCache) MGet(keys ...string) *MultiCmd {
+	localCmd := tc.local.MGet(keys...)
+	if localCmd.Error() != nil {
+		return tc.remote.MGet(keys...)
+	}
+	values := localCmd.Val()
+	missed := make([]string, 0)
+	index := make([]int, 0)
+	for i, cmd := range values {
+		if !cmd.Exists() || cmd.Error() != nil {
+			missed = append(missed, keys[i])
+			index = append(index, i)
+		}
+	}
+	if len(missed) == 0 {
+		return localCmd
+	}
+
+	remoteCmd := tc.remote.MGet(missed...)
+	if remoteCmd.Error() != nil {
+		return remoteCmd
+	}
+	for i, cmd := range remoteCmd.Val() {
+		values[index[i]] = cmd
+		if cmd.Exists() && cmd.Error() == nil {
+			tc.local.Set(missed[i], cmd.Val(), tc.localTTLOf(cmd.TTL()))
+		}
+	}
+	return &MultiCmd{value: values}
+}
+
+func (tc *TieredCache) MSet(values map[string]interface{}, ttl time.Duration) *StatusCmd {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	cmd := tc.remote.MSet(values, ttl)
+	if cmd.Error() != nil {
+		tc.invalidate(keys...)
+		return cmd
+	}
+	tc.local.MSet(values, tc.localTTLOf(ttl))
+	if len(keys) > 0 {
+		tc.publish(invalidateMessage{Keys: keys})
+	}
+	return cmd
+}
+
+func (tc *TieredCache) MDelete(keys ...string) *IntCmd {
+	cmd := tc.remote.MDelete(keys...)
+	tc.invalidate(keys...)
+	return cmd
+}
+
+func (tc *TieredCache) FlushAll() *StatusCmd {
+	cmd := tc.remote.FlushAll()
+	tc.local.FlushAll()
+	tc.publish(invalidateMessage{Flush: true})
+	return cmd
+}
+
+// Save 只保存 L2，L1 只是副本
+func (tc *TieredCache) Save() *StatusCmd {
+	return tc.remote.Save()
+}
+
+// Close 停止订阅，并关闭 L1、L2
+func (tc *TieredCache) Close() error {
+	err := tc.pubsub.Close()
+	tc.wg.Wait()
+	if e := tc.local.Close(); err == nil {
+		err = e
+	}
+	if e := tc.remote.Close(); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+var _ Cache = (*TieredCache)(nil)
+
+func newTestTieredCache(t *testing.T, s *miniredis.Miniredis) *TieredCache {
+	tc, err := NewTieredCache(NewMemCache(NewDefaultOptions()), NewRedisCache(RedisOptions{Addr: s.Addr()}),
+		TieredOptions{LocalTTL: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tc.Close() })
+	return tc
+}
+
+// waitFor 等待异步的失效通知
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTieredCache_GetSet(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	tc := newTestTieredCache(t, s)
+
+	if setCmd := tc.Set("k", "v", time.Minute); setCmd.Error() != nil {
+		t.Fatal(setCmd.Error())
+	}
+	if cmd := tc.Local().Get("k"); cmd.ValString() != "v" || cmd.TTL() > 10*time.Second {
+		t.Fatal(cmd.ValString(), cmd.TTL())
+	}
+	if ttl := s.TTL("k"); ttl != time.Minute {
+		t.Fatal(ttl)
+	}
+
+	// L2 命中后写入 L1，有效期不超过 L2
+	s.Set("remote", "1")
+	s.SetTTL("remote", 5*time.Second)
+	if cmd := tc.Get("remote"); cmd.ValString() != "1" {
+		t.Fatal(cmd.ValString())
+	}
+	if cmd := tc.Local().Get("remote"); !cmd.Exists() || cmd.TTL() > 5*time.Second {
+		t.Fatal(cmd.Exists(), cmd.TTL())
+	}
+
+	// 原子操作后删除 L1 副本
+	if cmd := tc.IncrBy("remote", 2, 0); cmd.Val() != 3 {
+		t.Fatal(cmd.Val(), cmd.Error())
+	}
+	if tc.Local().Get("remote").Exists() {
+		t.Fatal("local copy should be invalidated")
+	}
+	if cmd := tc.Get("remote"); cmd.ValString() != "3" {
+		t.Fatal(cmd.ValString())
+	}
+
+	mgetCmd := tc.MGet("k", "remote", "missing")
+	if mgetCmd.Error() != nil || len(mgetCmd.Val()) != 3 {
+		t.Fatal(mgetCmd.Error())
+	}
+	if vals := mgetCmd.Val(); vals[0].ValString() != "v" || vals[1].ValString() != "3" || vals[2].Exists() {
+		t.Fatal(vals[0].ValString(), vals[1].ValString(), vals[2].Exists())
+	}
+
+	if delCmd := tc.Delete("k"); delCmd.Error() != nil {
+		t.Fatal(delCmd.Error())
+	}
+	if tc.Local().Get("k").Exists() || s.Exists("k") {
+		t.Fatal("k should be deleted")
+	}
+}
+
+func TestTieredCache_Invalidate(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	a := newTestTieredCache(t, s)
+	b := newTestTieredCache(t, s)
+
+	a.Set("k", "v1", -1)
+	if cmd := b.Get("k"); cmd.ValString() != "v1" || !b.Local().Get("k").Exists() {
+		t.Fatal(cmd.ValString())
+	}
+
+	// a 更新后 b 的副本失效
+	a.Set("k", "v2", -1)
+	waitFor(t, func() bool { return !b.Local().Get("k").Exists() })
+	if cmd := b.Get("k"); cmd.ValString() != "v2" {
+		t.Fatal(cmd.ValString())
+	}
+	// 自己发出的通知不会删除自己的副本
+	if !a.Local().Get("k").Exists() {
+		t.Fatal("own message should be ignored")
+	}
+
+	b.MSet(map[string]interface{}{"x": 1, "y": 2}, time.Minute)
+	a.MGet("x", "y")
+	b.MDelete("x")
+	waitFor(t, func() bool { return !a.Local().Get("x").Exists() })
+	if !a.Local().Get("y").Exists() {
+		t.Fatal("y should not be invalidated")
+	}
+
+	a.FlushAll()
+	waitFor(t, func() bool { return !b.Local().Get("k").Exists() && !b.Local().Get("y").Exists() })
+	if keysCmd := b.Keys(""); len(keysCmd.Val()) != 0 {
+		t.Fatal(keysCmd.Val())
+	}
+}
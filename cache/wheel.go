@@ -0,0 +1,231 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+	分层时间轮，按过期时间删除 key
+Notice:
+	1. 开启 ExpireWheel 后每个带有过期时间的 key 按过期时间放入时间轮的桶中，到期时直接删除，不需要抽样或遍历
+	2. 共 wheelLevels 层，每层 wheelSlots 个桶，第 0 层每个桶为一个 tick，上一层每个桶为下一层一圈的时间，
+	   上层的桶到期时把其中的 key 重新放入下层，超出最上层范围的 key 放在最上层，到期时重新放入
+	3. 写入、删除、修改过期时间时在分片写锁内更新时间轮，时间轮有自己的锁，加锁顺序为 分片 -> 时间轮
+	4. 到期的 key 在分片写锁内再次检查过期时间，已经被删除或重新写入的 key 不会被误删
+	5. 回调在到期后的一个 tick 内执行，时间轮每个 key 额外占用两个 map 元素的内存
+*/
+
+const (
+	// DefaultExpireWheelTick 时间轮的精度
+	DefaultExpireWheelTick = 10 * time.Millisecond
+
+	wheelBits   = 6
+	wheelSlots  = 1 << wheelBits
+	wheelMask   = wheelSlots - 1
+	wheelLevels = 4
+)
+
+// wheelEntry key 在时间轮中的位置
+type wheelEntry struct {
+	level    int
+	slot     int
+	deadline int64
+}
+
+type timingWheel struct {
+	mutex   sync.Mutex
+	tick    time.Duration
+	start   time.Time
+	current int64
+	slots   [wheelLevels][wheelSlots]map[string]struct{}
+	entries map[string]wheelEntry
+}
+
+func newTimingWheel(tick time.Duration) *timingWheel {
+	if tick <= 0 {
+		tick = DefaultExpireWheelTick
+	}
+	return &timingWheel{
+		tick:    tick,
+		start:   time.Now(),
+		entries: make(map[string]wheelEntry),
+	}
+}
+
+// schedule 新增或更新 key 的过期时间，没有过期时间时移除
+func (w *timingWheel) schedule(key string, val WrapValue) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.removeLocked(key)
+	if val.Persistent() {
+		return
+	}
+	// 向上取整，处理桶时 key 一定已经过期
+	d := val.ExpiredTime.Sub(w.start)
+	deadline := int64((d + w.tick - 1) / w.tick)
+	w.placeLocked(key, deadline)
+}
+
+func (w *timingWheel) remove(key string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.removeLocked(key)
+}
+
+func (w *timingWheel) has(key string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	_, ok := w.entries[key]
+	return ok
+}
+
+func (w *timingWheel) reset() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.slots = [wheelLevels][wheelSlots]map[string]struct{}{}
+	w.entries = make(map[string]wheelEntry)
+}
+
+func (w *timingWheel) removeLocked(key string) {
+	e, ok := w.entries[key]
+	if !ok {
+		return
+	}
+	delete(w.slots[e.level][e.slot], key)
+	if len(w.slots[e.level][e.slot]) == 0 {
+		w.slots[e.level][e.slot] = nil
+	}
+	delete(w.entries, key)
+}
+
+// placeLocked 按距离当前 tick 的远近放入对应的层
+func (w *timingWheel) placeLocked(key string, deadline int64) {
+	d := deadline
+	if d < w.current {
+		d = w.current
+	}
+	idx := d - w.current
+	level := wheelLevels - 1
+	for l := 0; l < wheelLevels; l++ {
+		if idx < 1<<(wheelBits*(l+1)) {
+			level = l
+			break
+		}
+	}
+	if idx >= 1<<(wheelBits*wheelLevels) {
+		d = w.current + 1<<(wheelBits*wheelLevels) - 1
+	}
+	slot := int(d>>(wheelBits*level)) & wheelMask
+	if w.slots[level][slot] == nil {
+		w.slots[level][slot] = make(map[string]struct{})
+	}
+	w.slots[level][slot][key] = struct{}{}
+	w.entries[key] = wheelEntry{level: level, slot: slot, deadline: deadline}
+}
+
+// cascadeLocked 将上层桶中的 key 重新放入下层，返回桶的序号
+func (w *timingWheel) cascadeLocked(level int) int {
+	slot := int(w.current>>(wheelBits*level)) & wheelMask
+	keys := w.slots[level][slot]
+	w.slots[level][slot] = nil
+	for key := range keys {
+		w.placeLocked(key, w.entries[key].deadline)
+	}
+	return slot
+}
+
+// advance 处理到 now 为止的所有桶，返回到期的 key
+func (w *timingWheel) advance(now time.Time) []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	target := int64(now.Sub(w.start) / w.tick)
+	due := make([]string, 0)
+	for ; w.current <= target; w.current++ {
+		slot := int(w.current) & wheelMask
+		// 第 0 层转完一圈，上一层的桶到期，上一层同样转完一圈时继续向上
+		if slot == 0 {
+			for l := 1; l < wheelLevels; l++ {
+				if w.cascadeLocked(l) != 0 {
+					break
+				}
+			}
+		}
+		for key := range w.slots[0][slot] {
+			due = append(due, key)
+			delete(w.entries, key)
+		}
+		w.slots[0][slot] = nil
+	}
+	return due
+}
+
+// trackExpireLocked 记录 key 的过期时间，开启时间轮时使用时间轮，否则用于抽样清理，调用方持有分片写锁
+func (mem *MemCache) trackExpireLocked(sh *memShard, key string, val WrapValue) {
+	if mem.wheel != nil {
+		mem.wheel.schedule(key, val)
+		return
+	}
+	sh.volatileLocked(key, val)
+}
+
+func (mem *MemCache) untrackExpireLocked(sh *memShard, key string) {
+	if mem.wheel != nil {
+		mem.wheel.remove(key)
+		return
+	}
+	delete(sh.volatile, key)
+}
+
+func (mem *MemCache) startExpireWheel() {
+	mem.wg.Add(1)
+	go mem.runExpireWheel()
+}
+
+func (mem *MemCache) runExpireWheel() {
+	defer mem.wg.Done()
+	ticker := time.NewTicker(mem.wheel.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			mem.expireKeys(mem.wheel.advance(time.Now()))
+		case <-mem.closing:
+			return
+		}
+	}
+}
+
+// expireKeys 删除时间轮中到期的 key，每个分片只加一次锁
+func (mem *MemCache) expireKeys(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	byShard := make([][]string, len(mem.shards))
+	for _, key := range keys {
+		i := shardIndex(key, len(mem.shards))
+		byShard[i] = append(byShard[i], key)
+	}
+	for i, keys := range byShard {
+		if len(keys) == 0 {
+			continue
+		}
+		sh := mem.shards[i]
+		sh.rwMutex.Lock()
+		for _, key := range keys {
+			val, ok := sh.store[key]
+			if !ok {
+				continue
+			}
+			if val.Expired() {
+				mem.removeLocked(sh, key, val, RemoveExpired)
+				continue
+			}
+			// 过期时间恰好在 tick 边界时可能还没有过期，重新放入
+			if !mem.wheel.has(key) {
+				mem.wheel.schedule(key, val)
+			}
+		}
+		mem.unlock(sh)
+	}
+}
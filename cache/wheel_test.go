@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTimingWheel(t *testing.T) {
+	w := newTimingWheel(time.Millisecond)
+	at := func(tick int64) time.Time {
+		return w.start.Add(time.Duration(tick) * w.tick)
+	}
+	deadlines := map[string]int64{}
+	for _, d := range []int64{0, 1, 63, 64, 65, 127, 4095, 4096, 4097, 5000, 262143, 262144, 300000} {
+		key := fmt.Sprint(d)
+		deadlines[key] = d
+		w.schedule(key, WrapValue{ExpiredTime: at(d)})
+	}
+	// 重新设置过期时间和删除
+	w.schedule("moved", WrapValue{ExpiredTime: at(10)})
+	w.schedule("moved", WrapValue{ExpiredTime: at(7000)})
+	deadlines["moved"] = 7000
+	w.schedule("removed", WrapValue{ExpiredTime: at(100)})
+	w.remove("removed")
+	w.schedule("persistent", WrapValue{ExpiredTime: at(100)})
+	w.schedule("persistent", WrapValue{})
+
+	for tick := int64(0); tick <= 300000; tick++ {
+		for _, key := range w.advance(at(tick)) {
+			if deadlines[key] != tick {
+				t.Fatalf("%s expired at %d", key, tick)
+			}
+			delete(deadlines, key)
+		}
+	}
+	if len(deadlines) != 0 || len(w.entries) != 0 {
+		t.Fatal(deadlines, w.entries)
+	}
+
+	// 超出最上层范围
+	far := w.current + 1<<(wheelBits*wheelLevels) + 100
+	w.schedule("far", WrapValue{ExpiredTime: at(far)})
+	if due := w.advance(at(far - 1)); len(due) != 0 {
+		t.Fatal(due)
+	}
+	if due := w.advance(at(far)); len(due) != 1 {
+		t.Fatal(due)
+	}
+}
+
+func wheelLen(w *timingWheel) int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return len(w.entries)
+}
+
+func TestMemCache_ExpireWheel(t *testing.T) {
+	var expired int64
+	opt := NewDefaultOptions()
+	opt.Shards = 4
+	opt.ExpireWheel = true
+	opt.ExpireWheelTick = 5 * time.Millisecond
+	opt.OnExpire = func(key string, value interface{}, reason RemoveReason) {
+		atomic.AddInt64(&expired, 1)
+	}
+	memCache := NewMemCache(opt)
+	defer memCache.Close()
+
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		memCache.Set(fmt.Sprintf("k%d", i), i, 20*time.Millisecond)
+	}
+	memCache.Set("persistent", 1, -1)
+	memCache.Set("longer", 1, 20*time.Millisecond)
+	memCache.Expire("longer", time.Hour)
+	memCache.Set("persisted", 1, 20*time.Millisecond)
+	memCache.Persist("persisted")
+	memCache.Set("deleted", 1, 20*time.Millisecond)
+	memCache.Delete("deleted")
+
+	for atomic.LoadInt64(&expired) < 1000 {
+		if time.Since(start) > 2*time.Second {
+			t.Fatal(atomic.LoadInt64(&expired))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := memCache.Stats().Val().Keys; n != 3 {
+		t.Fatal(n)
+	}
+	if n := wheelLen(memCache.wheel); n != 1 {
+		t.Fatal(n)
+	}
+	if n := len(memCache.shards[0].volatile); n != 0 {
+		t.Fatal(n)
+	}
+
+	memCache.FlushAll()
+	if n := wheelLen(memCache.wheel); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestMemCache_ExpireWheelConcurrent(t *testing.T) {
+	opt := NewDefaultOptions()
+	opt.Shards = 4
+	opt.ExpireWheel = true
+	opt.ExpireWheelTick = time.Millisecond
+	memCache := NewMemCache(opt)
+	defer memCache.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < 2000; i++ {
+				key := fmt.Sprintf("k%d", r.Intn(100))
+				ttl := time.Duration(r.Intn(20)) * time.Millisecond
+				switch r.Intn(4) {
+				case 0:
+					memCache.Set(key, i, ttl)
+				case 1:
+					memCache.Expire(key, ttl)
+				case 2:
+					memCache.Persist(key)
+				case 3:
+					memCache.Delete(key)
+				}
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+	time.Sleep(50 * time.Millisecond)
+
+	// 带有过期时间的 key 都已经删除，时间轮中没有多余的 key
+	memCache.lockAll()
+	defer memCache.unlockAll()
+	for _, sh := range memCache.shards {
+		for key, val := range sh.store {
+			if !val.Persistent() {
+				t.Fatal(key, val.TTL())
+			}
+		}
+	}
+	if n := wheelLen(memCache.wheel); n != 0 {
+		t.Fatal(n)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/huzhongqing/gokit
 go 1.14
 
 require (
+	github.com/alicebob/miniredis/v2 v2.14.1
 	github.com/bsm/redislock v0.5.0
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/go-redis/redis/v7 v7.2.0